@@ -3,25 +3,23 @@ package controller
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/cache"
 	"github.com/michaelwp/goblog/dto"
 	"github.com/michaelwp/goblog/entities"
+	"github.com/michaelwp/goblog/metrics"
 	"github.com/michaelwp/goblog/model"
-	"github.com/michaelwp/goblog/tool"
-	"github.com/redis/go-redis/v9"
 	"log"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 type ArticleController interface {
 	CreateArticle(c *gin.Context)
 	GetArticleList(c *gin.Context)
 	UpdateArticle(c *gin.Context)
+	PatchArticle(c *gin.Context)
 	GetArticle(c *gin.Context)
 	DeleteArticle(c *gin.Context)
 }
@@ -41,52 +39,38 @@ func (a articleController) CreateArticle(c *gin.Context) {
 		Translate: "article.create.success",
 	}
 
-	var articleRequest entities.Article
-	err := c.ShouldBindJSON(&articleRequest)
-	if err != nil {
-		response.Status = ERROR
-		response.Message = tool.PrintLog("create_article:", err).Error()
-		response.Translate = "article.create.error"
+	var createRequest dto.CreateArticleRequest
+	if appErr := BindAndValidate(c, &createRequest); appErr != nil {
+		AbortWithError(c, appErr)
+		return
+	}
 
-		c.JSON(http.StatusBadRequest, response)
+	err := a.validateCategory(c, createRequest.CategoryId)
+	if err != nil {
+		AbortWithError(c, err)
 		return
 	}
 
+	articleRequest := createRequest.ToEntity()
+
 	userId, err := GetCurrentUserIdLoggedIn(c)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = tool.PrintLog("create_article:", err).Error()
-		response.Translate = "article.create.error"
-
-		c.JSON(http.StatusBadRequest, response)
+		AbortWithError(c, ErrUnauthorized.Wrap(err))
 		return
 	}
 
 	articleRequest.UserId = userId
 	articleRequest.CreatedBy = userId
 
-	articleModel := model.NewArticleModel(a.Config.Postgres)
-	_, err = articleModel.CreateArticle(c, &articleRequest)
+	_, err = a.ArticleModel.CreateArticle(c, articleRequest)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = tool.PrintLog("create_article:", err).Error()
-		response.Translate = "article.create.error"
-
-		c.JSON(http.StatusInternalServerError, response)
+		AbortWithError(c, ErrArticleCreate.Wrap(err))
 		return
 	}
 
-	err = a.RedisClient.Del(c, "articleList").Err()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.cache.delete.error"
+	a.Cache.InvalidateTags(c, cache.TAG_ARTICLES)
 
-		c.JSON(http.StatusBadRequest, response)
-		return
-	}
-
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusCreated, a.Localize(c, response))
 }
 
 func (a articleController) GetArticleList(c *gin.Context) {
@@ -96,116 +80,192 @@ func (a articleController) GetArticleList(c *gin.Context) {
 		Translate: "article.get.success",
 	}
 
-	result, err := a.RedisClient.Get(c, "articleList").Result()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.cache.get.error"
+	var articleList []*dto.ArticleWithExtend
 
-		c.JSON(http.StatusInternalServerError, response)
+	hit, err := a.Cache.Fetch(c, cache.KEY_ARTICLE_LIST, &articleList, cache.ARTICLE_TTL,
+		primaryLoader(a.loadArticleList))
+	if err != nil {
+		AbortWithError(c, cacheError(err))
 		return
 	}
 
-	if result != "" {
-		var articleList []*dto.ArticleWithExtend
+	if hit {
+		metrics.CacheHit(metrics.CACHE_ARTICLE_LIST)
+	} else {
+		metrics.CacheMiss(metrics.CACHE_ARTICLE_LIST)
+	}
 
-		err = json.Unmarshal([]byte(result), &articleList)
-		if err != nil {
-			response.Status = ERROR
-			response.Message = err.Error()
-			response.Translate = "article.json.unmarshal.error"
+	response.Data = articleList
+	c.JSON(200, a.Localize(c, response))
+}
 
-			c.JSON(http.StatusInternalServerError, response)
-			return
-		}
+func (a articleController) UpdateArticle(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "article successfully updated",
+		Translate: "article.update.success",
+	}
 
-		response.Data = articleList
-		c.JSON(200, response)
+	var updateRequest dto.UpdateArticleRequest
+	if appErr := BindAndValidate(c, &updateRequest); appErr != nil {
+		AbortWithError(c, appErr)
 		return
 	}
 
-	articleList, err := a.GroupingArticleList(c)
+	err := a.validateCategory(c, updateRequest.CategoryId)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = tool.PrintLog("get_article_list:", err).Error()
-		response.Translate = "article.get.error"
+		AbortWithError(c, err)
+		return
+	}
+
+	articleRequest := updateRequest.ToEntity()
+	articleIdStr := strconv.Itoa(int(articleRequest.Id))
 
-		c.JSON(http.StatusInternalServerError, response)
+	currArticle, err := a.FindCurrentArticle(c, articleIdStr)
+	if err != nil {
+		AbortWithError(c, err)
 		return
 	}
 
-	if articleList != nil {
-		err = a.cacheArticleList(c, "articleList", articleList)
-		if err != nil {
-			response.Status = ERROR
-			response.Message = err.Error()
-			response.Translate = "article.cache.set.error"
+	if appErr := CheckIfMatch(c, articleETag(currArticle)); appErr != nil {
+		AbortWithError(c, appErr)
+		return
+	}
 
-			c.JSON(http.StatusInternalServerError, response)
-			return
-		}
+	articleRequest.Version = currArticle.Version
+
+	err = a.UpdateCurrentArticle(c, articleRequest)
+	if err != nil {
+		AbortWithError(c, err)
+		return
 	}
 
-	response.Data = articleList
-	c.JSON(200, response)
+	a.Cache.InvalidateTags(c, cache.ArticleTag(articleRequest.Id), cache.TAG_ARTICLES)
+
+	// the new category name is part of the tag
+	currArticle, err = a.FindCurrentArticle(c, articleIdStr)
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", articleETag(currArticle))
+	c.JSON(200, a.Localize(c, response))
 }
 
-func (a articleController) UpdateArticle(c *gin.Context) {
+// PatchArticle applies a JSON Merge Patch, only the supplied columns are written.
+func (a articleController) PatchArticle(c *gin.Context) {
 	response := &Response{
 		Status:    SUCCESS,
 		Message:   "article successfully updated",
 		Translate: "article.update.success",
 	}
 
-	var articleRequest entities.Article
-	err := c.ShouldBindJSON(&articleRequest)
+	articleId := c.Param("id")
+	currArticle, err := a.FindCurrentArticle(c, articleId)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.update.error"
-
-		c.JSON(http.StatusBadRequest, response)
+		AbortWithError(c, err)
 		return
 	}
 
-	articleIdStr := strconv.Itoa(int(articleRequest.Id))
+	if appErr := CheckIfMatch(c, articleETag(currArticle)); appErr != nil {
+		AbortWithError(c, appErr)
+		return
+	}
 
-	err = a.UpdateCurrentArticle(c, &articleRequest)
-	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.update.error"
+	patchRequest := dto.CreateArticleRequest{
+		CategoryId:  currArticle.CategoryId,
+		Title:       currArticle.Title,
+		Content:     currArticle.Content,
+		Tags:        currArticle.Tags,
+		Description: currArticle.Description,
+		Image:       currArticle.Image,
+	}
 
-		c.JSON(http.StatusInternalServerError, response)
+	fields, appErr := BindMergePatch(c, &patchRequest)
+	if appErr != nil {
+		AbortWithError(c, appErr)
 		return
 	}
 
-	err = a.RedisClient.Del(c, "article:"+articleIdStr).Err()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.cache.delete.error"
+	if _, ok := fields["category_id"]; ok {
+		err = a.validateCategory(c, patchRequest.CategoryId)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+	}
+
+	if len(fields) > 0 {
+		userId, err := GetCurrentUserIdLoggedIn(c)
+		if err != nil {
+			AbortWithError(c, ErrUnauthorized.Wrap(err))
+			return
+		}
 
-		c.JSON(http.StatusBadRequest, response)
-		return
+		result, err := a.ArticleModel.PatchArticle(c, currArticle.Id, currArticle.Version, fields, userId)
+		if err != nil {
+			AbortWithError(c, ErrArticleUpdate.Wrap(err))
+			return
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+			AbortWithError(c, ErrPreconditionFailed)
+			return
+		}
+
+		a.Cache.InvalidateTags(c, cache.ArticleTag(currArticle.Id), cache.TAG_ARTICLES)
+
+		currArticle, err = a.FindCurrentArticle(c, articleId)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+	}
+
+	response.Data = currArticle
+	c.Header("ETag", articleETag(currArticle))
+	c.JSON(http.StatusOK, a.Localize(c, response))
+}
+
+// validateCategory returns ErrValidation when categoryId does not reference an existing category.
+func (a articleController) validateCategory(ctx context.Context, categoryId int64) error {
+	where := &model.Where{
+		Parameter: "WHERE id=$1",
+		Values:    []any{categoryId},
+	}
+
+	_, err := a.CategoryModel.FindCategory(ctx, where)
+	if err == nil {
+		return nil
 	}
 
-	c.JSON(200, response)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrValidation.WithFields([]*FieldError{
+			NewFieldError("category_id", "exists", "", "category_id must reference an existing category"),
+		})
+	}
+
+	return ErrCategoryGet.Wrap(err)
 }
 
 func (a articleController) UpdateCurrentArticle(ctx context.Context, articleRequest *entities.Article) (err error) {
 	userId, err := GetCurrentUserIdLoggedIn(ctx)
 	if err != nil {
-		return
+		return ErrUnauthorized.Wrap(err)
 	}
 
 	articleRequest.UserId = userId
 	articleRequest.UpdatedBy = &userId
 
-	articleModel := model.NewArticleModel(a.Config.Postgres)
-	_, err = articleModel.UpdateArticle(ctx, articleRequest)
+	result, err := a.ArticleModel.UpdateArticle(ctx, articleRequest)
 	if err != nil {
-		return
+		return ErrArticleUpdate.Wrap(err)
+	}
+
+	// the article was found before, so it has been modified in between
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return ErrPreconditionFailed
 	}
 
 	return
@@ -219,114 +279,126 @@ func (a articleController) GetArticle(c *gin.Context) {
 	}
 
 	articleId := c.Param("id")
-
-	result, err := a.RedisClient.Get(c, "article:"+articleId).Result()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.cache.get.error"
-
-		c.JSON(http.StatusInternalServerError, response)
+	articleIdInt, err := strconv.ParseInt(articleId, 10, 64)
+	if err != nil {
+		AbortWithError(c, ErrInvalidId.Wrap(err))
 		return
 	}
 
-	if result != "" {
-		var articleWithExtend dto.ArticleWithExtend
+	var article dto.ArticleWithExtend
 
-		err = json.Unmarshal([]byte(result), &articleWithExtend)
-		if err != nil {
-			response.Status = ERROR
-			response.Message = err.Error()
-			response.Translate = "article.json.unmarshal.error"
+	hit, err := a.Cache.Fetch(c, cache.ArticleKey(articleIdInt), &article, cache.ARTICLE_TTL,
+		primaryLoader(func(ctx context.Context) (any, []string, error) {
+			currArticle, err := a.FindCurrentArticle(ctx, articleId)
+			if err != nil {
+				return nil, nil, err
+			}
 
-			c.JSON(http.StatusInternalServerError, response)
-			return
-		}
-
-		response.Data = articleWithExtend
-		c.JSON(200, response)
+			return currArticle, articleTags(currArticle), nil
+		}))
+	if err != nil {
+		AbortWithError(c, cacheError(err))
 		return
 	}
 
-	currArticle, response, httpStatus, err := a.FindCurrentArticle(c, response, articleId)
-	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.get.error"
+	if hit {
+		metrics.CacheHit(metrics.CACHE_ARTICLE)
+	} else {
+		metrics.CacheMiss(metrics.CACHE_ARTICLE)
+	}
 
-		c.JSON(httpStatus, response)
+	if NotModified(c, articleETag(&article)) {
 		return
 	}
 
-	if currArticle != nil {
-		err = a.cacheArticle(c, "article:"+articleId, currArticle)
-		if err != nil {
-			response.Status = ERROR
-			response.Message = err.Error()
-			response.Translate = "article.cache.set.error"
+	response.Data = article
+	c.JSON(200, a.Localize(c, response))
+}
 
-			c.JSON(http.StatusInternalServerError, response)
-			return
-		}
+// loadArticleList is the cache loader of KEY_ARTICLE_LIST.
+func (a articleController) loadArticleList(ctx context.Context) (any, []string, error) {
+	articleList, err := a.GroupingArticleList(ctx)
+	if err != nil {
+		return nil, nil, ErrArticleGet.Wrap(err)
 	}
 
-	response.Data = currArticle
-	c.JSON(200, response)
+	return articleList, articleListTags(articleList), nil
 }
 
-func (a articleController) cacheArticle(ctx context.Context, key string, article *dto.ArticleWithExtend) error {
-	jsonData, err := json.Marshal(article)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Error marshalling JSON: %v", err))
+// primaryLoader runs load against the primary. A cached value is served to
+// everyone for its TTL, a fill from a lagging replica would undo the
+// invalidation of the write it missed.
+func primaryLoader(load cache.Loader) cache.Loader {
+	return func(ctx context.Context) (any, []string, error) {
+		return load(model.ReadPrimary(ctx))
 	}
+}
 
-	err = a.RedisClient.Set(ctx, key, jsonData, 1*time.Hour).Err()
-	if err != nil {
-		return errors.New(fmt.Sprintf("Error setting data in Redis: %v", err))
+// articleTags are everything an article embeds: itself, its category name
+// and its author's name, page and avatar.
+func articleTags(article *dto.ArticleWithExtend) []string {
+	return []string{
+		cache.ArticleTag(article.Id),
+		cache.CategoryTag(article.CategoryId),
+		cache.UserTag(article.UserId),
 	}
+}
 
-	return nil
+// articleETag also changes when the embedded category or author data does,
+// which is written without bumping the article version.
+func articleETag(article *dto.ArticleWithExtend) string {
+	return EmbeddingETag(article.Version, article.ArticleExtend)
 }
 
-func (a articleController) cacheArticleList(ctx context.Context, key string, articleList []*dto.ArticleWithExtend) error {
-	jsonData, err := json.Marshal(articleList)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Error marshalling JSON: %v", err))
+// articleListTags include TAG_ARTICLES, so creating, updating or deleting any
+// article evicts the list, and the category and author of every entry.
+func articleListTags(articleList []*dto.ArticleWithExtend) []string {
+	tags := []string{cache.TAG_ARTICLES}
+	seen := map[string]bool{}
+
+	for _, article := range articleList {
+		for _, tag := range []string{cache.CategoryTag(article.CategoryId), cache.UserTag(article.UserId)} {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
 	}
 
-	err = a.RedisClient.Set(ctx, key, jsonData, 1*time.Hour).Err()
-	if err != nil {
-		return errors.New(fmt.Sprintf("Error setting data in Redis: %v", err))
+	return tags
+}
+
+// cacheError passes through the AppError of a cache loader, anything else
+// comes from the cache itself.
+func cacheError(err error) *AppError {
+	var appError *AppError
+	if errors.As(err, &appError) {
+		return appError
 	}
 
-	return nil
+	return ErrArticleCache.Wrap(err)
 }
 
-func (a articleController) FindCurrentArticle(ctx context.Context, resp *Response, articleId string) (
-	currArticle *dto.ArticleWithExtend, response *Response, httpStatus int, err error) {
+func (a articleController) FindCurrentArticle(ctx context.Context, articleId string) (
+	currArticle *dto.ArticleWithExtend, err error) {
 
 	articleIdInt, err := strconv.ParseInt(articleId, 10, 64)
 	if err != nil {
-		response.Message = tool.PrintLog("FindCurrentArticle", err).Error()
-		return
+		return nil, ErrInvalidId.Wrap(err)
 	}
 
-	response = resp
 	where := &model.Where{
 		Parameter: "WHERE a.id=$1",
 		Values:    []any{articleIdInt},
 	}
 
-	articleModel := model.NewArticleModel(a.Config.Postgres)
-	currArticle, err = articleModel.FindArticle(ctx, where)
+	currArticle, err = a.ArticleModel.FindArticle(ctx, where)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			response.Message = tool.PrintLog("FindCurrentArticle", err).Error()
-			response.Translate = "article.not.found"
-			httpStatus = http.StatusNotFound
+			return nil, ErrArticleNotFound
 		}
 
-		return
+		return nil, ErrArticleGet.Wrap(err)
 	}
 
 	return
@@ -340,35 +412,37 @@ func (a articleController) DeleteArticle(c *gin.Context) {
 	}
 
 	articleId := c.Request.URL.Query().Get("id")
-	articleIdInt, err := strconv.ParseInt(articleId, 10, 64)
+	currArticle, err := a.FindCurrentArticle(c, articleId)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.delete.error"
+		AbortWithError(c, err)
+		return
+	}
 
-		c.JSON(http.StatusBadRequest, response)
+	if appErr := CheckIfMatch(c, articleETag(currArticle)); appErr != nil {
+		AbortWithError(c, appErr)
 		return
 	}
 
-	articleModel := model.NewArticleModel(a.Config.Postgres)
-	_, err = articleModel.DeleteArticle(c, articleIdInt)
+	result, err := a.ArticleModel.DeleteArticle(c, currArticle.Id, currArticle.Version)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "article.delete.error"
+		AbortWithError(c, ErrArticleDelete.Wrap(err))
+		return
+	}
 
-		c.JSON(http.StatusBadRequest, response)
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		AbortWithError(c, ErrPreconditionFailed)
 		return
 	}
 
-	c.JSON(200, response)
+	a.Cache.InvalidateTags(c, cache.ArticleTag(currArticle.Id), cache.TAG_ARTICLES)
+
+	c.JSON(200, a.Localize(c, response))
 }
 
 func (a articleController) GroupingArticleList(ctx context.Context) (
 	articleList []*dto.ArticleWithExtend, err error) {
 
-	articleModel := model.NewArticleModel(a.Config.Postgres)
-	articles, err := articleModel.GetAvailableCategoryId(ctx)
+	articles, err := a.ArticleModel.GetAvailableCategoryId(ctx)
 	if err != nil {
 		return
 	}
@@ -385,7 +459,7 @@ func (a articleController) GroupingArticleList(ctx context.Context) (
 			Limit:     "LIMIT 20",
 		}
 
-		subArticleList, err := articleModel.GetArticleList(ctx, where)
+		subArticleList, err := a.ArticleModel.GetArticleList(ctx, where)
 		if err != nil {
 			return nil, err
 		}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/metrics"
 	"github.com/michaelwp/goblog/model"
 	"github.com/michaelwp/goblog/tool"
 	"net/http"
@@ -40,41 +41,29 @@ func (a authorizationController) Login(c *gin.Context) {
 	var loginCredential LoginCredential
 	err := c.ShouldBindJSON(&loginCredential)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = tool.PrintLog("login", err).Error()
-		response.Translate = "user.error.login"
-
-		c.JSON(http.StatusUnauthorized, response)
+		metrics.LoginFailure()
+		AbortWithError(c, ErrInvalidBody.Wrap(err))
 		return
 	}
 
 	userId, token, err := a.LoginProcess(c, &loginCredential)
 	if err != nil {
-		translate := "user.error.login"
-		if errors.Is(err, sql.ErrNoRows) {
-			translate = "email.or.password.not.found"
-		}
-
-		response.Status = ERROR
-		response.Message = tool.PrintLog("login", err).Error()
-		response.Translate = translate
-
-		c.JSON(http.StatusUnauthorized, response)
+		metrics.LoginFailure()
+		AbortWithError(c, err)
 		return
 	}
 
 	err = a.RedisClient.Set(c, strconv.FormatInt(userId, 10), token, 24*time.Hour).Err()
 	if err != nil {
-		response.Status = ERROR
-		response.Message = tool.PrintLog("login", err).Error()
-		response.Translate = "user.error.login"
-
-		c.JSON(http.StatusUnauthorized, response)
+		metrics.LoginFailure()
+		AbortWithError(c, ErrLogin.Wrap(err))
 		return
 	}
 
+	metrics.LoginSuccess()
+
 	response.Data = map[string]interface{}{"token": token}
-	c.JSON(http.StatusAccepted, response)
+	c.JSON(http.StatusAccepted, a.Localize(c, response))
 }
 
 func (a authorizationController) LoginProcess(ctx context.Context, cred *LoginCredential) (
@@ -85,20 +74,23 @@ func (a authorizationController) LoginProcess(ctx context.Context, cred *LoginCr
 		Values:    []interface{}{cred.Email, model.ACTIVE},
 	}
 
-	userModel := model.NewUserModel(a.Config.Postgres)
-	currUser, err := userModel.FindUser(ctx, where)
+	currUser, err := a.UserModel.FindUser(ctx, where)
 	if err != nil {
-		return
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", ErrInvalidCredentials
+		}
+
+		return 0, "", ErrLogin.Wrap(err)
 	}
 
 	err = tool.CompareHashAndPassword([]byte(currUser.Password), []byte(cred.Password))
 	if err != nil {
-		return
+		return 0, "", ErrInvalidCredentials.Wrap(err)
 	}
 
-	token, err = tool.GenerateJWT(currUser.Id)
+	token, err = tool.GenerateJWT(currUser.Id, a.JwtSigningKey)
 	if err != nil {
-		return
+		return 0, "", ErrLogin.Wrap(err)
 	}
 
 	userId = currUser.Id
@@ -114,23 +106,15 @@ func (a authorizationController) Logout(c *gin.Context) {
 
 	userId, err := GetCurrentUserIdLoggedIn(c)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = tool.PrintLog("login", err).Error()
-		response.Translate = "user.error.logout"
-
-		c.JSON(http.StatusUnauthorized, response)
+		AbortWithError(c, ErrUnauthorized.Wrap(err))
 		return
 	}
 
 	err = a.RedisClient.Del(c, strconv.FormatInt(userId, 10)).Err()
 	if err != nil {
-		response.Status = ERROR
-		response.Message = tool.PrintLog("login", err).Error()
-		response.Translate = "user.error.logout"
-
-		c.JSON(http.StatusUnauthorized, response)
+		AbortWithError(c, ErrLogout.Wrap(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, a.Localize(c, response))
 }
@@ -5,15 +5,20 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/cache"
+	"github.com/michaelwp/goblog/dto"
 	"github.com/michaelwp/goblog/entities"
 	"github.com/michaelwp/goblog/model"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type CategoryController interface {
 	CreateCategory(c *gin.Context)
 	GetCategoryList(c *gin.Context)
 	UpdateCategory(c *gin.Context)
+	PatchCategory(c *gin.Context)
 	GetCategory(c *gin.Context)
 }
 
@@ -32,54 +37,54 @@ func (g categoryController) CreateCategory(c *gin.Context) {
 		Translate: "category.create.success",
 	}
 
-	var categoryRequest entities.Category
-	err := c.ShouldBindJSON(&categoryRequest)
-	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "category.create.error"
-
-		c.JSON(http.StatusBadRequest, response)
+	var createRequest dto.CreateCategoryRequest
+	if appErr := BindAndValidate(c, &createRequest); appErr != nil {
+		AbortWithError(c, appErr)
 		return
 	}
 
-	err = g.InsertCategory(c, &categoryRequest)
+	err := g.InsertCategory(c, createRequest.ToEntity())
 	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "category.create.error"
-
-		c.JSON(http.StatusInternalServerError, response)
+		AbortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusCreated, g.Localize(c, response))
 }
 
+// InsertCategory checks the name and creates the category in one transaction,
+// a concurrent insert of the same name ends in a unique violation mapped to
+// ErrCategoryExists.
 func (g categoryController) InsertCategory(ctx context.Context, categoryRequest *entities.Category) (err error) {
 	where := &model.Where{
 		Parameter: "WHERE name=$1",
-		Values:    []any{categoryRequest.Name},
-	}
-
-	categoryModel := model.NewCategoryModel(g.Config.Postgres)
-	currCategory, err := categoryModel.FindCategory(ctx, where)
-	if !errors.Is(err, sql.ErrNoRows) && err != nil {
-		return
-	}
-
-	if currCategory != nil && currCategory.Name != "" {
-		return errors.New("category already registered")
+		Values:    []any{strings.ToLower(categoryRequest.Name)},
 	}
 
 	categoryRequest.CreatedBy, err = GetCurrentUserIdLoggedIn(ctx)
 	if err != nil {
-		return
+		return ErrUnauthorized.Wrap(err)
 	}
 
-	_, err = categoryModel.CreateCategory(ctx, categoryRequest)
-	if err != nil {
-		return
+	err = g.Transactor.Transaction(ctx, func(ctx context.Context, work *model.UnitOfWork) error {
+		currCategory, err := work.CategoryModel.FindCategory(ctx, where)
+		if !errors.Is(err, sql.ErrNoRows) && err != nil {
+			return ErrCategoryGet.Wrap(err)
+		}
+
+		if currCategory != nil && currCategory.Name != "" {
+			return ErrCategoryExists
+		}
+
+		_, err = work.CategoryModel.CreateCategory(ctx, categoryRequest)
+		if err != nil {
+			return ErrCategoryCreate.Wrap(err)
+		}
+
+		return nil
+	})
+	if model.IsUniqueViolation(err) {
+		return ErrCategoryExists.Wrap(err)
 	}
 
 	return
@@ -92,19 +97,14 @@ func (g categoryController) GetCategoryList(c *gin.Context) {
 		Translate: "category.get.success",
 	}
 
-	categoryModel := model.NewCategoryModel(g.Config.Postgres)
-	categoryList, err := categoryModel.GetCategoryList(c, nil)
+	categoryList, err := g.CategoryModel.GetCategoryList(c, nil)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "category.get.error"
-
-		c.JSON(http.StatusInternalServerError, response)
+		AbortWithError(c, ErrCategoryGet.Wrap(err))
 		return
 	}
 
 	response.Data = categoryList
-	c.JSON(200, response)
+	c.JSON(200, g.Localize(c, response))
 }
 
 func (g categoryController) UpdateCategory(c *gin.Context) {
@@ -114,7 +114,7 @@ func (g categoryController) UpdateCategory(c *gin.Context) {
 		Translate: "category.update.success",
 	}
 
-	c.JSON(200, response)
+	c.JSON(200, g.Localize(c, response))
 }
 
 func (g categoryController) GetCategory(c *gin.Context) {
@@ -124,31 +124,138 @@ func (g categoryController) GetCategory(c *gin.Context) {
 		Translate: "category.get.success",
 	}
 
-	categoryId := c.Param("id")
+	categoryId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		AbortWithError(c, ErrInvalidId.Wrap(err))
+		return
+	}
+
 	where := &model.Where{
 		Parameter: "WHERE id=$1",
 		Values:    []any{categoryId},
 	}
 
-	categoryModel := model.NewCategoryModel(g.Config.Postgres)
-	currCategory, err := categoryModel.FindCategory(c, where)
+	currCategory, err := g.CategoryModel.FindCategory(c, where)
 	if err != nil {
-		translate := "category.get.error"
-		httpStatus := http.StatusInternalServerError
-
 		if errors.Is(err, sql.ErrNoRows) {
-			translate = "category.not.found"
-			httpStatus = http.StatusNotFound
+			AbortWithError(c, ErrCategoryNotFound)
+			return
 		}
 
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = translate
+		AbortWithError(c, ErrCategoryGet.Wrap(err))
+		return
+	}
+
+	if NotModified(c, ETag(currCategory.Version)) {
+		return
+	}
+
+	response.Data = currCategory
+	c.JSON(200, g.Localize(c, response))
+}
+
+// PatchCategory applies a JSON Merge Patch, only the supplied columns are written.
+func (g categoryController) PatchCategory(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "category successfully updated",
+		Translate: "category.update.success",
+	}
+
+	categoryId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		AbortWithError(c, ErrInvalidId.Wrap(err))
+		return
+	}
+
+	currCategory, err := g.findCategory(c, &model.Where{
+		Parameter: "WHERE id=$1",
+		Values:    []any{categoryId},
+	})
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
 
-		c.JSON(httpStatus, response)
+	if appErr := CheckIfMatch(c, ETag(currCategory.Version)); appErr != nil {
+		AbortWithError(c, appErr)
 		return
 	}
 
+	patchRequest := dto.CreateCategoryRequest{
+		Name: currCategory.Name,
+	}
+
+	fields, appErr := BindMergePatch(c, &patchRequest)
+	if appErr != nil {
+		AbortWithError(c, appErr)
+		return
+	}
+
+	if len(fields) > 0 {
+		sameName, err := g.findCategory(c, &model.Where{
+			Parameter: "WHERE name=$1 AND id<>$2",
+			Values:    []any{strings.ToLower(patchRequest.Name), categoryId},
+		})
+		if err == nil && sameName != nil {
+			AbortWithError(c, ErrCategoryExists)
+			return
+		}
+
+		if err != nil && !errors.Is(err, ErrCategoryNotFound) {
+			AbortWithError(c, err)
+			return
+		}
+
+		userId, err := GetCurrentUserIdLoggedIn(c)
+		if err != nil {
+			AbortWithError(c, ErrUnauthorized.Wrap(err))
+			return
+		}
+
+		result, err := g.CategoryModel.PatchCategory(c, categoryId, currCategory.Version, fields, userId)
+		if model.IsUniqueViolation(err) {
+			AbortWithError(c, ErrCategoryExists.Wrap(err))
+			return
+		}
+
+		if err != nil {
+			AbortWithError(c, ErrCategoryUpdate.Wrap(err))
+			return
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+			AbortWithError(c, ErrPreconditionFailed)
+			return
+		}
+
+		// cached articles embed the category name
+		g.Cache.InvalidateTags(c, cache.CategoryTag(categoryId))
+
+		currCategory, err = g.findCategory(c, &model.Where{
+			Parameter: "WHERE id=$1",
+			Values:    []any{categoryId},
+		})
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+	}
+
 	response.Data = currCategory
-	c.JSON(200, response)
+	c.Header("ETag", ETag(currCategory.Version))
+	c.JSON(http.StatusOK, g.Localize(c, response))
+}
+
+func (g categoryController) findCategory(ctx context.Context, where *model.Where) (*entities.Category, error) {
+	currCategory, err := g.CategoryModel.FindCategory(ctx, where)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+
+		return nil, ErrCategoryGet.Wrap(err)
+	}
+
+	return currCategory, nil
 }
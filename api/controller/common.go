@@ -4,7 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/michaelwp/goblog/cache"
+	"github.com/michaelwp/goblog/config"
+	"github.com/michaelwp/goblog/i18n"
+	"github.com/michaelwp/goblog/model"
+	"github.com/michaelwp/goblog/storage"
 	"github.com/redis/go-redis/v9"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -14,6 +22,7 @@ const (
 
 type Response struct {
 	Status    string `json:"status"`
+	Code      string `json:"code,omitempty"`
 	Message   string `json:"message"`
 	Translate string `json:"translate"`
 	Data      any    `json:"data,omitempty"`
@@ -21,8 +30,88 @@ type Response struct {
 }
 
 type Config struct {
-	Postgres    *sql.DB
-	RedisClient *redis.Client
+	Postgres      *sql.DB
+	Replicas      *model.ReplicaRouter
+	ArticleModel  model.ArticleModel
+	UserModel     model.UserModel
+	CategoryModel model.CategoryModel
+	MediaModel    model.MediaModel
+	Transactor    model.Transactor
+	RedisClient   *redis.Client
+	Cache         cache.Cache
+	Storage       storage.Storage
+	JwtSigningKey []byte
+	RateLimit     *config.RateLimitConfig
+	CORS          *config.CORSConfig
+	Security      *config.SecurityConfig
+	Idempotency   *config.IdempotencyConfig
+	Auth          *config.AuthConfig
+	Media         *config.MediaConfig
+	// ProblemJSON renders errors as RFC 7807 application/problem+json for
+	// every client instead of only those asking for it in Accept.
+	ProblemJSON bool
+	// ReadYourWrites is how long a user's reads go to the primary after a
+	// write, zero when there are no replicas.
+	ReadYourWrites time.Duration
+	// I18n resolves Translate keys to messages in the request locale.
+	// Messages are left untouched when it is nil.
+	I18n *i18n.Catalog
+
+	shuttingDown atomic.Bool
+	workers      sync.WaitGroup
+	workersOnce  sync.Once
+	workersCtx   context.Context
+	stopWorkers  context.CancelFunc
+}
+
+// SetShuttingDown makes the readiness probe fail so load balancers stop
+// routing new requests while in-flight ones are drained.
+func (c *Config) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+func (c *Config) IsShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Go runs a background worker. The context passed to fn is cancelled by Close,
+// which then waits for the worker to return.
+func (c *Config) Go(fn func(ctx context.Context)) {
+	c.workersOnce.Do(c.initWorkers)
+
+	c.workers.Add(1)
+	go func() {
+		defer c.workers.Done()
+		fn(c.workersCtx)
+	}()
+}
+
+func (c *Config) initWorkers() {
+	c.workersCtx, c.stopWorkers = context.WithCancel(context.Background())
+}
+
+// Close stops the background workers, waits for them and then closes
+// the Postgres, replica and Redis clients.
+func (c *Config) Close() error {
+	c.workersOnce.Do(c.initWorkers)
+	c.stopWorkers()
+	c.workers.Wait()
+
+	var errs []error
+
+	if c.RedisClient != nil {
+		errs = append(errs, c.RedisClient.Close())
+	}
+
+	if c.Replicas != nil {
+		errs = append(errs, c.Replicas.Close())
+	}
+
+	if c.Postgres != nil {
+		errs = append(errs, c.Postgres.Close())
+	}
+
+	return errors.Join(errs...)
 }
 
 func GetCurrentUserIdLoggedIn(ctx context.Context) (userId int64, err error) {
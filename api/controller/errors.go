@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/tool"
+	"net/http"
+	"strings"
+)
+
+const PROBLEM_JSON = "application/problem+json"
+
+// AppError is the error every handler reports through c.Error. Code is the
+// machine-readable identifier, Message is safe to show to clients and Err is
+// the internal cause, which is logged but never rendered.
+type AppError struct {
+	Code       string
+	HttpStatus int
+	Translate  string
+	Message    string
+	Fields     []*FieldError
+	Err        error
+}
+
+func NewAppError(code string, httpStatus int, translate, message string) *AppError {
+	return &AppError{
+		Code:       code,
+		HttpStatus: httpStatus,
+		Translate:  translate,
+		Message:    message,
+	}
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+
+	return e.Code + ": " + e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches on Code so errors.Is(err, ErrArticleNotFound) holds for wrapped copies.
+func (e *AppError) Is(target error) bool {
+	appError, ok := target.(*AppError)
+	return ok && appError.Code == e.Code
+}
+
+// Wrap returns a copy of e carrying err as its internal cause.
+func (e *AppError) Wrap(err error) *AppError {
+	wrapped := *e
+	wrapped.Err = err
+
+	return &wrapped
+}
+
+func (e *AppError) WithFields(fields []*FieldError) *AppError {
+	wrapped := *e
+	wrapped.Fields = fields
+
+	return &wrapped
+}
+
+var (
+	ErrInternal        = NewAppError("INTERNAL_ERROR", http.StatusInternalServerError, "internal.error", "internal server error")
+	ErrInvalidBody     = NewAppError("INVALID_REQUEST_BODY", http.StatusBadRequest, "request.body.invalid", "invalid request body")
+	ErrInvalidId       = NewAppError("INVALID_ID", http.StatusBadRequest, "request.id.invalid", "id must be a positive integer")
+	ErrValidation      = NewAppError("VALIDATION_FAILED", http.StatusUnprocessableEntity, "validation.failed", "validation failed")
+	ErrBodyTooLarge    = NewAppError("REQUEST_BODY_TOO_LARGE", http.StatusRequestEntityTooLarge, "request.body.too.large", "request body too large")
+	ErrTooManyRequests = NewAppError("RATE_LIMITED", http.StatusTooManyRequests, "rate.limit.exceeded", "too many requests")
+	ErrUnauthorized    = NewAppError("UNAUTHORIZED", http.StatusUnauthorized, "unauthorized", "unauthorized")
+	ErrForbidden       = NewAppError("FORBIDDEN", http.StatusForbidden, "forbidden", "forbidden")
+	ErrMediaType       = NewAppError("UNSUPPORTED_MEDIA_TYPE", http.StatusUnsupportedMediaType, "request.media.type.unsupported", "unsupported media type")
+
+	ErrIdempotencyKey        = NewAppError("INVALID_IDEMPOTENCY_KEY", http.StatusBadRequest, "idempotency.key.invalid", "Idempotency-Key must be 1 to 255 characters")
+	ErrIdempotencyKeyReused  = NewAppError("IDEMPOTENCY_KEY_REUSED", http.StatusUnprocessableEntity, "idempotency.key.reused", "Idempotency-Key was already used with a different request")
+	ErrIdempotencyInProgress = NewAppError("IDEMPOTENCY_KEY_IN_PROGRESS", http.StatusConflict, "idempotency.key.in.progress", "a request with this Idempotency-Key is still in progress")
+
+	ErrSessionUnavailable = NewAppError("SESSION_STORE_UNAVAILABLE", http.StatusServiceUnavailable, "session.store.unavailable", "session store unavailable, try again later")
+	ErrLogin              = NewAppError("LOGIN_FAILED", http.StatusInternalServerError, "user.error.login", "login failed")
+	ErrInvalidCredentials = NewAppError("INVALID_CREDENTIALS", http.StatusUnauthorized, "email.or.password.not.found", "email or password is incorrect")
+	ErrLogout             = NewAppError("LOGOUT_FAILED", http.StatusInternalServerError, "user.error.logout", "logout failed")
+
+	ErrArticleNotFound = NewAppError("ARTICLE_NOT_FOUND", http.StatusNotFound, "article.not.found", "article not found")
+	ErrArticleGet      = NewAppError("ARTICLE_GET_FAILED", http.StatusInternalServerError, "article.get.error", "article could not be retrieved")
+	ErrArticleCreate   = NewAppError("ARTICLE_CREATE_FAILED", http.StatusInternalServerError, "article.create.error", "article could not be created")
+	ErrArticleUpdate   = NewAppError("ARTICLE_UPDATE_FAILED", http.StatusInternalServerError, "article.update.error", "article could not be updated")
+	ErrArticleDelete   = NewAppError("ARTICLE_DELETE_FAILED", http.StatusInternalServerError, "article.delete.error", "article could not be deleted")
+	ErrArticleCache    = NewAppError("ARTICLE_CACHE_FAILED", http.StatusInternalServerError, "article.cache.error", "article cache unavailable")
+
+	ErrCategoryNotFound = NewAppError("CATEGORY_NOT_FOUND", http.StatusNotFound, "category.not.found", "category not found")
+	ErrCategoryGet      = NewAppError("CATEGORY_GET_FAILED", http.StatusInternalServerError, "category.get.error", "category could not be retrieved")
+	ErrCategoryCreate   = NewAppError("CATEGORY_CREATE_FAILED", http.StatusInternalServerError, "category.create.error", "category could not be created")
+	ErrCategoryUpdate   = NewAppError("CATEGORY_UPDATE_FAILED", http.StatusInternalServerError, "category.update.error", "category could not be updated")
+	ErrCategoryExists   = NewAppError("CATEGORY_ALREADY_EXISTS", http.StatusConflict, "category.already.exists", "category already registered")
+
+	ErrUserNotFound = NewAppError("USER_NOT_FOUND", http.StatusNotFound, "user.not.found", "user not found")
+	ErrUserGet      = NewAppError("USER_GET_FAILED", http.StatusInternalServerError, "user.get.error", "user could not be retrieved")
+	ErrUserCreate   = NewAppError("USER_CREATE_FAILED", http.StatusInternalServerError, "user.create.error", "user could not be created")
+	ErrUserUpdate   = NewAppError("USER_UPDATE_FAILED", http.StatusInternalServerError, "user.update.error", "user could not be updated")
+	ErrEmailExists  = NewAppError("EMAIL_ALREADY_REGISTERED", http.StatusConflict, "user.email.already.registered", "email already registered")
+
+	ErrMediaNotFound = NewAppError("MEDIA_NOT_FOUND", http.StatusNotFound, "media.not.found", "media not found")
+	ErrMediaGet      = NewAppError("MEDIA_GET_FAILED", http.StatusInternalServerError, "media.get.error", "media could not be retrieved")
+	ErrMediaUpload   = NewAppError("MEDIA_UPLOAD_FAILED", http.StatusInternalServerError, "media.upload.error", "media could not be uploaded")
+	ErrMediaDelete   = NewAppError("MEDIA_DELETE_FAILED", http.StatusInternalServerError, "media.delete.error", "media could not be deleted")
+	ErrMediaInUse    = NewAppError("MEDIA_IN_USE", http.StatusConflict, "media.in.use", "media is used by an article or a user")
+)
+
+// Problem is the RFC 7807 representation of an AppError, extended with
+// the code, translate key and field errors of the standard Response.
+type Problem struct {
+	Type      string        `json:"type"`
+	Title     string        `json:"title"`
+	Status    int           `json:"status"`
+	Detail    string        `json:"detail"`
+	Instance  string        `json:"instance,omitempty"`
+	Code      string        `json:"code"`
+	Translate string        `json:"translate"`
+	Errors    []*FieldError `json:"errors,omitempty"`
+}
+
+// RenderError writes err as the standard Response envelope, or as
+// application/problem+json when config.ProblemJSON is set or the client asks
+// for it. Errors that are not an AppError are rendered as ErrInternal; the
+// internal cause is only logged. Messages are localized to the request locale.
+func RenderError(c *gin.Context, err error, config *Config) {
+	var appError *AppError
+	if !errors.As(err, &appError) {
+		appError = ErrInternal.Wrap(err)
+	}
+
+	if appError.Err != nil {
+		tool.PrintLog(appError.Code, appError.Err)
+	}
+
+	// copy before localizing, the package level errors are shared
+	localized := *appError
+	localized.Fields = make([]*FieldError, 0, len(appError.Fields))
+	for _, fieldError := range appError.Fields {
+		fieldErrorCopy := *fieldError
+		localized.Fields = append(localized.Fields, &fieldErrorCopy)
+	}
+
+	appError = &localized
+
+	if config.I18n != nil {
+		appError.Message = config.I18n.Translate(config.Locale(c), appError.Translate, appError.Message, nil)
+		config.localizeFieldErrors(c, appError.Fields)
+	}
+
+	if config.ProblemJSON || strings.Contains(c.GetHeader("Accept"), PROBLEM_JSON) {
+		problem, _ := json.Marshal(&Problem{
+			Type:      "urn:goblog:problem:" + strings.ToLower(appError.Code),
+			Title:     http.StatusText(appError.HttpStatus),
+			Status:    appError.HttpStatus,
+			Detail:    appError.Message,
+			Instance:  c.Request.URL.Path,
+			Code:      appError.Code,
+			Translate: appError.Translate,
+			Errors:    appError.Fields,
+		})
+
+		c.Data(appError.HttpStatus, PROBLEM_JSON, problem)
+		return
+	}
+
+	response := &Response{
+		Status:    ERROR,
+		Code:      appError.Code,
+		Message:   appError.Message,
+		Translate: appError.Translate,
+		HttpCode:  appError.HttpStatus,
+	}
+
+	if len(appError.Fields) > 0 {
+		response.Data = &ValidationErrors{Errors: appError.Fields}
+	}
+
+	c.JSON(appError.HttpStatus, response)
+}
+
+// AbortWithError records err for the error middleware and stops the chain.
+func AbortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
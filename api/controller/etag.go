@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrPreconditionRequired = NewAppError("PRECONDITION_REQUIRED", http.StatusPreconditionRequired, "precondition.required", "If-Match header is required")
+	ErrPreconditionFailed   = NewAppError("PRECONDITION_FAILED", http.StatusPreconditionFailed, "precondition.failed", "resource has been modified")
+)
+
+// ETag is the strong entity tag of a resource version.
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// EmbeddingETag is the strong entity tag of a resource version that embeds
+// data of other rows, which change without bumping it. The tag also hashes
+// embedded so that it changes with them.
+func EmbeddingETag(version int64, embedded any) string {
+	body, err := json.Marshal(embedded)
+	if err != nil {
+		return ETag(version)
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write(body)
+
+	return `"` + strconv.FormatInt(version, 10) + "-" + strconv.FormatUint(hash.Sum64(), 16) + `"`
+}
+
+// CheckIfMatch requires an If-Match header listing etag, the current tag of the
+// resource, or "*". Its answer only holds until the conditional write, which
+// must also check the version.
+func CheckIfMatch(c *gin.Context, etag string) *AppError {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		return ErrPreconditionRequired
+	}
+
+	if !matchETag(ifMatch, etag, false) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// NotModified sets the ETag header and answers 304 when If-None-Match already
+// lists it. Handlers return without a body when it reports true.
+func NotModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch == "" || !matchETag(ifNoneMatch, etag, true) {
+		return false
+	}
+
+	c.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// matchETag reports whether header, a list of entity tags or "*", contains etag.
+// If-None-Match uses the weak comparison, which ignores the W/ prefix.
+func matchETag(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
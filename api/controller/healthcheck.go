@@ -1,9 +1,38 @@
 package controller
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
 )
 
+const HEALTH_CHECK_TIMEOUT = 2 * time.Second
+
+const (
+	DEPENDENCY_UP   = "up"
+	DEPENDENCY_DOWN = "down"
+)
+
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type HealthController interface {
+	Liveness(c *gin.Context)
+	Readiness(c *gin.Context)
+}
+
+type healthController struct {
+	*Config
+}
+
+func NewHealthController(c *Config) HealthController {
+	return &healthController{c}
+}
+
 func HealthCheck(c *gin.Context) {
 	c.JSON(200, Response{
 		Status:    SUCCESS,
@@ -11,3 +40,76 @@ func HealthCheck(c *gin.Context) {
 		Translate: "hello.from.GoBlog",
 	})
 }
+
+// Liveness only reports that the process is able to serve requests,
+// it does not check any dependency.
+func (h healthController) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, h.Localize(c, &Response{
+		Status:    SUCCESS,
+		Message:   "alive",
+		Translate: "health.alive",
+	}))
+}
+
+// Readiness checks every dependency and answers 503 when Postgres is down or
+// when the server is shutting down. Redis is only a cache and session store,
+// the API keeps serving without it so its outage is reported as degraded.
+func (h healthController) Readiness(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "ready",
+		Translate: "health.ready",
+	}
+
+	dependencies := map[string]*DependencyStatus{
+		"postgres": h.checkDependency(c, func(ctx context.Context) error {
+			return h.Postgres.PingContext(ctx)
+		}),
+		"redis": h.checkDependency(c, func(ctx context.Context) error {
+			return h.RedisClient.Ping(ctx).Err()
+		}),
+	}
+
+	httpStatus := http.StatusOK
+	if dependencies["redis"].Status != DEPENDENCY_UP {
+		response.Message = "ready, degraded"
+		response.Translate = "health.degraded"
+	}
+
+	if dependencies["postgres"].Status != DEPENDENCY_UP {
+		response.Status = ERROR
+		response.Message = "dependency unavailable"
+		response.Translate = "health.not.ready"
+		httpStatus = http.StatusServiceUnavailable
+	}
+
+	if h.IsShuttingDown() {
+		response.Status = ERROR
+		response.Message = "server is shutting down"
+		response.Translate = "health.shutting.down"
+		httpStatus = http.StatusServiceUnavailable
+	}
+
+	response.Data = dependencies
+	c.JSON(httpStatus, h.Localize(c, response))
+}
+
+func (h healthController) checkDependency(ctx context.Context, ping func(ctx context.Context) error) *DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+
+	dependency := &DependencyStatus{
+		Status:    DEPENDENCY_UP,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		dependency.Status = DEPENDENCY_DOWN
+		dependency.Error = err.Error()
+	}
+
+	return dependency
+}
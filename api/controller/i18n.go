@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// LOCALE is the gin context key holding the locale negotiated by LocaleMiddleware.
+const LOCALE = "locale"
+
+var ErrLocaleNotFound = NewAppError("LOCALE_NOT_FOUND", http.StatusNotFound, "locale.not.found", "locale not found")
+
+type I18nController interface {
+	GetCatalog(c *gin.Context)
+}
+
+type i18nController struct {
+	*Config
+}
+
+func NewI18nController(c *Config) I18nController {
+	return &i18nController{c}
+}
+
+// GetCatalog returns every message of a locale, with keys it does not
+// translate filled from the default locale.
+func (i i18nController) GetCatalog(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "catalog successfully retrieved",
+		Translate: "i18n.get.success",
+	}
+
+	if i.I18n == nil {
+		AbortWithError(c, ErrLocaleNotFound)
+		return
+	}
+
+	messages, ok := i.I18n.Messages(c.Param("locale"))
+	if !ok {
+		AbortWithError(c, ErrLocaleNotFound)
+		return
+	}
+
+	response.Data = messages
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, i.Localize(c, response))
+}
+
+// Locale returns the locale negotiated for the request, or the default one
+// when LocaleMiddleware did not run.
+func (c *Config) Locale(ctx *gin.Context) string {
+	if locale := ctx.GetString(LOCALE); locale != "" {
+		return locale
+	}
+
+	if c.I18n == nil {
+		return ""
+	}
+
+	return c.I18n.Negotiate(ctx.GetHeader("Accept-Language"))
+}
+
+// Localize replaces response.Message with the translation of response.Translate
+// in the request locale. The message is kept when the key is not in the catalog.
+func (c *Config) Localize(ctx *gin.Context, response *Response) *Response {
+	if c.I18n == nil {
+		return response
+	}
+
+	response.Message = c.I18n.Translate(c.Locale(ctx), response.Translate, response.Message, nil)
+	return response
+}
+
+// localizeFieldErrors translates each field error, preferring a message for the
+// specific field (validation.title.max) over the generic rule (validation.max).
+func (c *Config) localizeFieldErrors(ctx *gin.Context, fieldErrors []*FieldError) {
+	if c.I18n == nil {
+		return
+	}
+
+	locale := c.Locale(ctx)
+	for _, fieldError := range fieldErrors {
+		args := map[string]string{"field": fieldError.Field, "param": fieldError.Param}
+
+		message := c.I18n.Translate(locale, fieldError.Translate, "", args)
+		if message == "" {
+			message = c.I18n.Translate(locale, "validation."+fieldError.Rule, fieldError.Message, args)
+		}
+
+		fieldError.Message = message
+	}
+}
@@ -0,0 +1,427 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/entities"
+	"github.com/michaelwp/goblog/model"
+	"github.com/michaelwp/goblog/storage"
+	"github.com/michaelwp/goblog/tool"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MEDIA_FORM_FIELD = "file"
+	// MEDIA_SNIFF_LENGTH is how much http.DetectContentType looks at.
+	MEDIA_SNIFF_LENGTH    = 512
+	MEDIA_FILE_NAME_LIMIT = 255
+	// MEDIA_CACHE_CONTROL lets clients keep files forever, a key is never reused.
+	MEDIA_CACHE_CONTROL = "public, max-age=31536000, immutable"
+)
+
+// mediaExtensions are preferred over mime.ExtensionsByType, which returns
+// e.g. .jfif before .jpg.
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type MediaController interface {
+	UploadMedia(c *gin.Context)
+	GetMediaList(c *gin.Context)
+	GetMedia(c *gin.Context)
+	DeleteMedia(c *gin.Context)
+	GetMediaFile(c *gin.Context)
+}
+
+type mediaController struct {
+	*Config
+}
+
+func NewMediaController(c *Config) MediaController {
+	return &mediaController{c}
+}
+
+// UploadMedia stores the multipart file of MEDIA_FORM_FIELD. The type is
+// sniffed from the content, the client's Content-Type is ignored. A file the
+// user already uploaded, by checksum, returns the existing media with 200.
+func (m mediaController) UploadMedia(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "media successfully uploaded",
+		Translate: "media.upload.success",
+	}
+
+	userId, err := GetCurrentUserIdLoggedIn(c)
+	if err != nil {
+		AbortWithError(c, ErrUnauthorized.Wrap(err))
+		return
+	}
+
+	fileHeader, err := c.FormFile(MEDIA_FORM_FIELD)
+	if err != nil {
+		AbortWithError(c, ErrValidation.WithFields([]*FieldError{
+			NewFieldError(MEDIA_FORM_FIELD, "required", "", MEDIA_FORM_FIELD+" is required"),
+		}))
+		return
+	}
+
+	if fileHeader.Size > m.Media.MaxSize {
+		AbortWithError(c, ErrBodyTooLarge.Wrap(
+			errors.New("file exceeds "+strconv.FormatInt(m.Media.MaxSize, 10)+" bytes")))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		AbortWithError(c, ErrInvalidBody.Wrap(err))
+		return
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	media, err := m.inspect(file)
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	if !m.Media.Allows(media.ContentType) {
+		AbortWithError(c, ErrMediaType.Wrap(errors.New("content type "+media.ContentType+" is not allowed")))
+		return
+	}
+
+	sameFile := &model.Where{
+		Parameter: "WHERE owner_id=$1 AND checksum=$2",
+		Values:    []any{userId, media.Checksum},
+	}
+
+	existing, err := m.MediaModel.FindMedia(c, sameFile)
+	if err == nil {
+		m.uploadedBefore(c, response, existing)
+		return
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		AbortWithError(c, ErrMediaGet.Wrap(err))
+		return
+	}
+
+	media.OwnerId = userId
+	media.FileName = mediaFileName(fileHeader.Filename)
+	media.StorageKey, err = mediaKey(media.ContentType)
+	if err != nil {
+		AbortWithError(c, ErrMediaUpload.Wrap(err))
+		return
+	}
+
+	media.Url = strings.TrimSuffix(m.Media.PublicURL, "/") + "/" + media.StorageKey
+
+	err = m.Storage.Put(c.Request.Context(), media.StorageKey, file, media.Size, media.ContentType)
+	if err != nil {
+		AbortWithError(c, ErrMediaUpload.Wrap(err))
+		return
+	}
+
+	_, err = m.MediaModel.CreateMedia(c, media)
+	if err != nil {
+		m.deleteFile(c.Request.Context(), media.StorageKey)
+
+		// a concurrent upload of the same file was inserted first
+		if model.IsUniqueViolation(err) {
+			existing, err = m.findMedia(c, sameFile)
+			if err != nil {
+				AbortWithError(c, err)
+				return
+			}
+
+			m.uploadedBefore(c, response, existing)
+			return
+		}
+
+		AbortWithError(c, ErrMediaUpload.Wrap(err))
+		return
+	}
+
+	created, err := m.findMedia(c, &model.Where{
+		Parameter: "WHERE storage_key=$1",
+		Values:    []any{media.StorageKey},
+	})
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	response.Data = created
+	c.JSON(http.StatusCreated, m.Localize(c, response))
+}
+
+// uploadedBefore answers an upload of a file the user already has with its media.
+func (m mediaController) uploadedBefore(c *gin.Context, response *Response, existing *entities.Media) {
+	response.Message, response.Translate = "media already uploaded", "media.upload.exists"
+	response.Data = existing
+	c.JSON(http.StatusOK, m.Localize(c, response))
+}
+
+// inspect sniffs the content type, hashes and measures file, then rewinds it.
+func (m mediaController) inspect(file io.ReadSeeker) (*entities.Media, error) {
+	head := make([]byte, MEDIA_SNIFF_LENGTH)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, ErrInvalidBody.Wrap(err)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+
+	hash := sha256.New()
+	hash.Write(head[:n])
+	rest, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, ErrInvalidBody.Wrap(err)
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, ErrMediaUpload.Wrap(err)
+	}
+
+	return &entities.Media{
+		ContentType: contentType,
+		Size:        int64(n) + rest,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// GetMediaList returns the media of the current user, most recent first.
+func (m mediaController) GetMediaList(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "media successfully retrieved",
+		Translate: "media.get.success",
+	}
+
+	userId, err := GetCurrentUserIdLoggedIn(c)
+	if err != nil {
+		AbortWithError(c, ErrUnauthorized.Wrap(err))
+		return
+	}
+
+	mediaList, err := m.MediaModel.GetMediaList(c, &model.Where{
+		Parameter: "WHERE owner_id=$1",
+		Values:    []any{userId},
+		Order:     "ORDER BY id DESC",
+	})
+	if err != nil {
+		AbortWithError(c, ErrMediaGet.Wrap(err))
+		return
+	}
+
+	response.Data = mediaList
+	c.JSON(http.StatusOK, m.Localize(c, response))
+}
+
+func (m mediaController) GetMedia(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "media successfully retrieved",
+		Translate: "media.get.success",
+	}
+
+	media, err := m.ownMedia(c)
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	response.Data = media
+	c.JSON(http.StatusOK, m.Localize(c, response))
+}
+
+// DeleteMedia deletes media no article or user references, its owner or an
+// admin may delete it.
+func (m mediaController) DeleteMedia(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "media successfully deleted",
+		Translate: "media.delete.success",
+	}
+
+	media, err := m.ownMedia(c)
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	if media.Usage > 0 {
+		AbortWithError(c, ErrMediaInUse)
+		return
+	}
+
+	result, err := m.MediaModel.DeleteMedia(c, media.Id)
+	if err != nil {
+		AbortWithError(c, ErrMediaDelete.Wrap(err))
+		return
+	}
+
+	// deleted or referenced since it was read
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		_, err = m.findMedia(c, &model.Where{
+			Parameter: "WHERE id=$1",
+			Values:    []any{media.Id},
+		})
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		AbortWithError(c, ErrMediaInUse)
+		return
+	}
+
+	m.deleteFile(c.Request.Context(), media.StorageKey)
+
+	c.JSON(http.StatusOK, m.Localize(c, response))
+}
+
+// GetMediaFile serves a stored file, it is public like the articles using it.
+func (m mediaController) GetMediaFile(c *gin.Context) {
+	media, err := m.findMedia(c, &model.Where{
+		Parameter: "WHERE storage_key=$1",
+		Values:    []any{strings.TrimPrefix(c.Param("key"), "/")},
+	})
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	etag := `"` + media.Checksum + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	file, err := m.Storage.Open(c.Request.Context(), media.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		AbortWithError(c, ErrMediaNotFound.Wrap(err))
+		return
+	}
+
+	if err != nil {
+		AbortWithError(c, ErrMediaGet.Wrap(err))
+		return
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", MEDIA_CACHE_CONTROL)
+	c.DataFromReader(http.StatusOK, media.Size, media.ContentType, file, nil)
+}
+
+// ownMedia returns the media of the id parameter when the current user owns
+// it or is an admin.
+func (m mediaController) ownMedia(c *gin.Context) (*entities.Media, error) {
+	mediaId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidId.Wrap(err)
+	}
+
+	userId, err := GetCurrentUserIdLoggedIn(c)
+	if err != nil {
+		return nil, ErrUnauthorized.Wrap(err)
+	}
+
+	media, err := m.findMedia(c, &model.Where{
+		Parameter: "WHERE id=$1",
+		Values:    []any{mediaId},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if media.OwnerId == userId {
+		return media, nil
+	}
+
+	user, err := m.UserModel.FindUser(c, &model.Where{
+		Parameter: "WHERE id=$1",
+		Values:    []any{userId},
+	})
+	if err != nil {
+		return nil, ErrUserGet.Wrap(err)
+	}
+
+	if !user.Admin {
+		// as if it did not exist, ids of other users' media are not disclosed
+		return nil, ErrMediaNotFound
+	}
+
+	return media, nil
+}
+
+func (m mediaController) findMedia(ctx context.Context, where *model.Where) (*entities.Media, error) {
+	media, err := m.MediaModel.FindMedia(ctx, where)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+
+		return nil, ErrMediaGet.Wrap(err)
+	}
+
+	return media, nil
+}
+
+// deleteFile removes a file whose row is gone, a failure only leaves an
+// unreferenced file behind.
+func (m mediaController) deleteFile(ctx context.Context, key string) {
+	err := m.Storage.Delete(context.WithoutCancel(ctx), key)
+	if err != nil {
+		tool.PrintLog("media delete "+key, err)
+	}
+}
+
+// mediaKey returns a random key under the current month, e.g. 2026/10/<hex>.png.
+func mediaKey(contentType string) (string, error) {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+
+	extension, ok := mediaExtensions[contentType]
+	if !ok {
+		if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+			extension = extensions[0]
+		}
+	}
+
+	return time.Now().UTC().Format("2006/01") + "/" + hex.EncodeToString(random) + extension, nil
+}
+
+// mediaFileName keeps the base name the client sent, for display only.
+func mediaFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if len(name) > MEDIA_FILE_NAME_LIMIT {
+		name = strings.ToValidUTF8(name[:MEDIA_FILE_NAME_LIMIT], "")
+	}
+
+	return name
+}
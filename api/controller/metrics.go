@@ -0,0 +1,11 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/metrics"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func Metrics() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+}
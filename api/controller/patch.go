@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/michaelwp/goblog/tool"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const MERGE_PATCH_JSON = "application/merge-patch+json"
+
+// BindMergePatch applies the JSON Merge Patch (RFC 7396) in the request body to
+// request, which must hold the current state of the resource, and validates the
+// result with its binding tags. It returns the patched values of the members
+// the client supplied, keyed by their json name.
+func BindMergePatch(c *gin.Context, request any) (fields map[string]any, appErr *AppError) {
+	contentType := c.ContentType()
+	if contentType != MERGE_PATCH_JSON && contentType != binding.MIMEJSON {
+		return nil, ErrMediaType
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, ErrInvalidBody.Wrap(err)
+	}
+
+	patch, err := tool.ParseMergePatch(body)
+	if err != nil {
+		return nil, ErrInvalidBody.Wrap(err)
+	}
+
+	values := jsonFields(reflect.ValueOf(request).Elem())
+
+	var unknown []*FieldError
+	for name := range patch {
+		if _, ok := values[name]; !ok {
+			unknown = append(unknown, NewFieldError(name, "unknown", "", name+" cannot be patched"))
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Slice(unknown, func(i, j int) bool { return unknown[i].Field < unknown[j].Field })
+		return nil, ErrValidation.WithFields(unknown)
+	}
+
+	document, err := json.Marshal(request)
+	if err != nil {
+		return nil, ErrInternal.Wrap(err)
+	}
+
+	merged, err := tool.MergePatch(document, body)
+	if err != nil {
+		return nil, ErrInvalidBody.Wrap(err)
+	}
+
+	// start from zero values so members removed by the patch end up empty
+	reflect.ValueOf(request).Elem().SetZero()
+	err = json.Unmarshal(merged, request)
+	if err != nil {
+		return nil, bindingError(err)
+	}
+
+	err = binding.Validator.ValidateStruct(request)
+	if err != nil {
+		return nil, bindingError(err)
+	}
+
+	fields = make(map[string]any, len(patch))
+	for name := range patch {
+		fields[name] = values[name].Interface()
+	}
+
+	return fields, nil
+}
+
+// jsonFields indexes the fields of a struct, including embedded ones, by json name.
+func jsonFields(value reflect.Value) map[string]reflect.Value {
+	fields := map[string]reflect.Value{}
+
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		if structField.Anonymous && structField.Type.Kind() == reflect.Struct {
+			for name, field := range jsonFields(value.Field(i)) {
+				fields[name] = field
+			}
+
+			continue
+		}
+
+		name := strings.Split(structField.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fields[name] = value.Field(i)
+	}
+
+	return fields
+}
@@ -5,16 +5,21 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/cache"
+	"github.com/michaelwp/goblog/dto"
 	"github.com/michaelwp/goblog/entities"
 	"github.com/michaelwp/goblog/model"
 	"github.com/michaelwp/goblog/tool"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type UserController interface {
 	CreateUser(c *gin.Context)
 	GetUserList(c *gin.Context)
 	UpdateUser(c *gin.Context)
+	PatchUser(c *gin.Context)
 	GetUser(c *gin.Context)
 }
 
@@ -33,55 +38,56 @@ func (u userController) CreateUser(c *gin.Context) {
 		Translate: "user.create.success",
 	}
 
-	var userRequest entities.User
-	err := c.ShouldBindJSON(&userRequest)
-	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "user.create.error"
-
-		c.JSON(http.StatusBadRequest, response)
+	var createRequest dto.CreateUserRequest
+	if appErr := BindAndValidate(c, &createRequest); appErr != nil {
+		AbortWithError(c, appErr)
 		return
 	}
 
-	err = u.InsertUser(c, &userRequest)
+	err := u.InsertUser(c, createRequest.ToEntity())
 	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "user.create.error"
-
-		c.JSON(http.StatusInternalServerError, response)
+		AbortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusCreated, u.Localize(c, response))
 }
 
+// InsertUser checks the email and creates the user in one transaction, a
+// concurrent insert of the same email ends in a unique violation mapped to
+// ErrEmailExists.
 func (u userController) InsertUser(ctx context.Context, userRequest *entities.User) (err error) {
 	where := &model.Where{
 		Parameter: "WHERE email=$1",
-		Values:    []any{userRequest.Email},
-	}
-
-	userModel := model.NewUserModel(u.Config.Postgres)
-	currUser, err := userModel.FindUser(ctx, where)
-	if !errors.Is(err, sql.ErrNoRows) && err != nil {
-		return
-	}
-
-	if currUser != nil && currUser.Email != "" {
-		return errors.New("email already registered")
+		Values:    []any{strings.ToLower(userRequest.Email)},
 	}
 
+	// hashed outside the transaction, it may run more than once
 	hash, err := tool.GenerateHash([]byte(userRequest.Password))
 	if err != nil {
-		return
+		return ErrUserCreate.Wrap(err)
 	}
 
 	userRequest.Password = string(hash)
-	_, err = userModel.CreateUser(ctx, userRequest)
-	if err != nil {
-		return
+	err = u.Transactor.Transaction(ctx, func(ctx context.Context, work *model.UnitOfWork) error {
+		currUser, err := work.UserModel.FindUser(ctx, where)
+		if !errors.Is(err, sql.ErrNoRows) && err != nil {
+			return ErrUserGet.Wrap(err)
+		}
+
+		if currUser != nil && currUser.Email != "" {
+			return ErrEmailExists
+		}
+
+		_, err = work.UserModel.CreateUser(ctx, userRequest)
+		if err != nil {
+			return ErrUserCreate.Wrap(err)
+		}
+
+		return nil
+	})
+	if model.IsUniqueViolation(err) {
+		return ErrEmailExists.Wrap(err)
 	}
 
 	return
@@ -94,19 +100,14 @@ func (u userController) GetUserList(c *gin.Context) {
 		Translate: "user.get.success",
 	}
 
-	userModel := model.NewUserModel(u.Config.Postgres)
-	userList, err := userModel.GetUserList(c, nil)
+	userList, err := u.UserModel.GetUserList(c, nil)
 	if err != nil {
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = "user.get.error"
-
-		c.JSON(http.StatusInternalServerError, response)
+		AbortWithError(c, ErrUserGet.Wrap(err))
 		return
 	}
 
 	response.Data = userList
-	c.JSON(200, response)
+	c.JSON(200, u.Localize(c, response))
 }
 
 func (u userController) UpdateUser(c *gin.Context) {
@@ -116,7 +117,7 @@ func (u userController) UpdateUser(c *gin.Context) {
 		Translate: "user.update.success",
 	}
 
-	c.JSON(200, response)
+	c.JSON(200, u.Localize(c, response))
 }
 
 func (u userController) GetUser(c *gin.Context) {
@@ -126,31 +127,191 @@ func (u userController) GetUser(c *gin.Context) {
 		Translate: "user.get.success",
 	}
 
-	userId := c.Param("id")
+	userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		AbortWithError(c, ErrInvalidId.Wrap(err))
+		return
+	}
+
 	where := &model.Where{
 		Parameter: "WHERE id=$1",
 		Values:    []any{userId},
 	}
 
-	userModel := model.NewUserModel(u.Config.Postgres)
-	currUser, err := userModel.FindUser(c, where)
+	currUser, err := u.UserModel.FindUser(c, where)
 	if err != nil {
-		translate := "user.get.error"
-		httpStatus := http.StatusInternalServerError
-
 		if errors.Is(err, sql.ErrNoRows) {
-			translate = "user.not.found"
-			httpStatus = http.StatusNotFound
+			AbortWithError(c, ErrUserNotFound)
+			return
 		}
 
-		response.Status = ERROR
-		response.Message = err.Error()
-		response.Translate = translate
+		AbortWithError(c, ErrUserGet.Wrap(err))
+		return
+	}
 
-		c.JSON(httpStatus, response)
+	if NotModified(c, ETag(currUser.Version)) {
 		return
 	}
 
 	response.Data = currUser
-	c.JSON(200, response)
+	c.JSON(200, u.Localize(c, response))
+}
+
+// PatchUser applies a JSON Merge Patch to a user, only the supplied columns are
+// written. Users may only patch themselves unless they are an admin.
+func (u userController) PatchUser(c *gin.Context) {
+	response := &Response{
+		Status:    SUCCESS,
+		Message:   "user successfully updated",
+		Translate: "user.update.success",
+	}
+
+	userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		AbortWithError(c, ErrInvalidId.Wrap(err))
+		return
+	}
+
+	loggedInUserId, err := GetCurrentUserIdLoggedIn(c)
+	if err != nil {
+		AbortWithError(c, ErrUnauthorized.Wrap(err))
+		return
+	}
+
+	if loggedInUserId != userId {
+		loggedInUser, err := u.findUser(c, loggedInUserId)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		if !loggedInUser.Admin {
+			AbortWithError(c, ErrForbidden)
+			return
+		}
+	}
+
+	currUser, err := u.findUser(c, userId)
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	if appErr := CheckIfMatch(c, ETag(currUser.Version)); appErr != nil {
+		AbortWithError(c, appErr)
+		return
+	}
+
+	patchRequest := dto.PatchUserRequest{
+		Name:   currUser.Name,
+		Email:  currUser.Email,
+		Avatar: currUser.Avatar,
+		Page:   currUser.Page,
+	}
+
+	fields, appErr := BindMergePatch(c, &patchRequest)
+	if appErr != nil {
+		AbortWithError(c, appErr)
+		return
+	}
+
+	// a null password removes the member, which binding accepts as omitted
+	if _, ok := fields["password"]; ok && patchRequest.Password == "" {
+		AbortWithError(c, ErrValidation.WithFields([]*FieldError{
+			NewFieldError("password", "required", "", "password is required"),
+		}))
+		return
+	}
+
+	if _, ok := fields["email"]; ok {
+		// stored lower-cased like on create, the unique index is case-sensitive
+		patchRequest.Email = strings.ToLower(patchRequest.Email)
+		fields["email"] = patchRequest.Email
+	}
+
+	if _, ok := fields["email"]; ok && patchRequest.Email != currUser.Email {
+		_, err = u.UserModel.FindUser(c, &model.Where{
+			Parameter: "WHERE email=$1 AND id<>$2",
+			Values:    []any{patchRequest.Email, userId},
+		})
+		if err == nil {
+			AbortWithError(c, ErrEmailExists)
+			return
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			AbortWithError(c, ErrUserGet.Wrap(err))
+			return
+		}
+	}
+
+	if _, ok := fields["password"]; ok {
+		hash, err := tool.GenerateHash([]byte(patchRequest.Password))
+		if err != nil {
+			AbortWithError(c, ErrUserUpdate.Wrap(err))
+			return
+		}
+
+		fields["password"] = string(hash)
+	}
+
+	if len(fields) > 0 {
+		result, err := u.UserModel.PatchUser(c, userId, currUser.Version, fields, loggedInUserId)
+		if model.IsUniqueViolation(err) {
+			AbortWithError(c, ErrEmailExists.Wrap(err))
+			return
+		}
+
+		if err != nil {
+			AbortWithError(c, ErrUserUpdate.Wrap(err))
+			return
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+			AbortWithError(c, ErrPreconditionFailed)
+			return
+		}
+
+		if embedsUserFields(fields) {
+			u.Cache.InvalidateTags(c, cache.UserTag(userId))
+		}
+
+		currUser, err = u.findUser(c, userId)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+	}
+
+	currUser.Password = ""
+	response.Data = currUser
+	c.Header("ETag", ETag(currUser.Version))
+	c.JSON(http.StatusOK, u.Localize(c, response))
+}
+
+// embedsUserFields reports whether fields touch the author data cached articles embed.
+func embedsUserFields(fields map[string]any) bool {
+	for _, name := range []string{"name", "page", "avatar"} {
+		if _, ok := fields[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (u userController) findUser(ctx context.Context, userId int64) (*entities.User, error) {
+	currUser, err := u.UserModel.FindUser(ctx, &model.Where{
+		Parameter: "WHERE id=$1",
+		Values:    []any{userId},
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, ErrUserGet.Wrap(err)
+	}
+
+	return currUser, nil
 }
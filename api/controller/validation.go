@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+type FieldError struct {
+	Field     string `json:"field"`
+	Rule      string `json:"rule"`
+	Param     string `json:"param,omitempty"`
+	Message   string `json:"message"`
+	Translate string `json:"translate"`
+}
+
+type ValidationErrors struct {
+	Errors []*FieldError `json:"errors"`
+}
+
+func init() {
+	// report json field names (category_id) instead of Go ones (CategoryId)
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				return ""
+			}
+
+			return name
+		})
+
+		_ = engine.RegisterValidation("media_url", func(field validator.FieldLevel) bool {
+			return IsMediaURL(field.Field().String())
+		})
+	}
+}
+
+// IsMediaURL accepts an absolute http or https URL, or an absolute path on
+// this host such as the URL of media served by the API.
+func IsMediaURL(value string) bool {
+	if strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//") {
+		_, err := url.ParseRequestURI(value)
+		return err == nil
+	}
+
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func NewFieldError(field, rule, param, message string) *FieldError {
+	return &FieldError{
+		Field:     field,
+		Rule:      rule,
+		Param:     param,
+		Message:   message,
+		Translate: "validation." + field + "." + rule,
+	}
+}
+
+// BindAndValidate binds the request body into request and validates its binding tags.
+// It returns ErrInvalidBody for a malformed body and ErrValidation listing every
+// failing field otherwise.
+func BindAndValidate(c *gin.Context, request any) *AppError {
+	err := c.ShouldBindJSON(request)
+	if err == nil {
+		return nil
+	}
+
+	return bindingError(err)
+}
+
+// bindingError converts a binding or validator error into an AppError.
+func bindingError(err error) *AppError {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fieldErrors := make([]*FieldError, 0, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			fieldErrors = append(fieldErrors, NewFieldError(
+				fieldError.Field(),
+				fieldError.Tag(),
+				fieldError.Param(),
+				fieldErrorMessage(fieldError),
+			))
+		}
+
+		return ErrValidation.WithFields(fieldErrors)
+	}
+
+	return ErrInvalidBody.Wrap(err)
+}
+
+func fieldErrorMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fieldError.Field())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters", fieldError.Field(), fieldError.Param())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s characters", fieldError.Field(), fieldError.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", fieldError.Field(), fieldError.Param())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", fieldError.Field())
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", fieldError.Field())
+	case "media_url":
+		return fmt.Sprintf("%s must be a valid URL or media path", fieldError.Field())
+	default:
+		return fmt.Sprintf("%s is invalid (%s)", fieldError.Field(), fieldError.Tag())
+	}
+}
@@ -11,27 +11,47 @@ func NewRouter(r *gin.Engine, config *controller.Config) {
 	categoryController := controller.NewCategoryController(config)
 	authorizationController := controller.NewAuthorizationController(config)
 	articleController := controller.NewArticleController(config)
+	i18nController := controller.NewI18nController(config)
+	healthController := controller.NewHealthController(config)
+	mediaController := controller.NewMediaController(config)
+
+	r.Use(middleware.TracingMiddleware())
+	r.Use(middleware.MetricsMiddleware())
+	r.Use(middleware.LocaleMiddleware(config.I18n))
+	r.Use(middleware.ErrorMiddleware(config))
+	// registered on the engine so preflight requests, which match no route, are answered too
+	r.Use(middleware.CORSMiddleware(config.CORS))
+	r.Use(middleware.SecurityHeadersMiddleware(config.Security))
+	r.Use(middleware.ReadYourWritesMiddleware(config))
 
 	r.GET("/ping", controller.HealthCheck)
+	r.GET("/healthz", healthController.Liveness)
+	r.GET("/readyz", healthController.Readiness)
+	r.GET("/metrics", controller.Metrics())
 
 	api := r.Group("/api")
 	v1 := api.Group("/v1")
 
-	v1.Use(middleware.CORSMiddleware())
-
 	User(v1, userController, config)
 	Article(v1, articleController, config)
 	Category(v1, categoryController, config)
 	Authorization(v1, authorizationController, config)
+	Media(v1, mediaController, config)
+	I18n(v1, i18nController, config)
 }
 
 func User(r *gin.RouterGroup, controller controller.UserController, config *controller.Config) {
-	users := r.Group("/users").Use(middleware.AuthMiddleware(config))
+	users := r.Group("/users").Use(
+		middleware.AuthMiddleware(config),
+		middleware.RateLimitMiddleware(config, config.RateLimit.Authenticated()),
+		middleware.BodyLimitMiddleware(config.Security.MaxBodySize),
+	)
 	//users := r.Group("/users")
 	{
-		users.POST("/create", controller.CreateUser)
+		users.POST("/create", middleware.IdempotencyMiddleware(config), controller.CreateUser)
 		users.GET("", controller.GetUserList)
 		users.PUT("/update", controller.UpdateUser)
+		users.PATCH("/:id", controller.PatchUser)
 		users.GET("/:id", controller.GetUser)
 	}
 }
@@ -39,12 +59,18 @@ func User(r *gin.RouterGroup, controller controller.UserController, config *cont
 func Article(r *gin.RouterGroup, controller controller.ArticleController, config *controller.Config) {
 	articles := r.Group("/articles")
 	{
-		articles.GET("", controller.GetArticleList)
-		articles.GET("/:id", controller.GetArticle)
-		articles.Use(middleware.AuthMiddleware(config))
+		public := articles.Group("", middleware.RateLimitMiddleware(config, config.RateLimit.Public()))
+		public.GET("", controller.GetArticleList)
+		public.GET("/:id", controller.GetArticle)
+		articles.Use(
+			middleware.AuthMiddleware(config),
+			middleware.RateLimitMiddleware(config, config.RateLimit.Authenticated()),
+			middleware.BodyLimitMiddleware(config.Security.MaxArticleBodySize),
+		)
 		{
-			articles.POST("/create", controller.CreateArticle)
+			articles.POST("/create", middleware.IdempotencyMiddleware(config), controller.CreateArticle)
 			articles.PUT("/update", controller.UpdateArticle)
+			articles.PATCH("/:id", controller.PatchArticle)
 			articles.DELETE("/delete", controller.DeleteArticle)
 		}
 	}
@@ -53,12 +79,40 @@ func Article(r *gin.RouterGroup, controller controller.ArticleController, config
 func Category(r *gin.RouterGroup, controller controller.CategoryController, config *controller.Config) {
 	categories := r.Group("/categories")
 	{
-		categories.GET("", controller.GetCategoryList)
-		categories.GET("/:id", controller.GetCategory)
-		categories.Use(middleware.AuthMiddleware(config))
+		public := categories.Group("", middleware.RateLimitMiddleware(config, config.RateLimit.Public()))
+		public.GET("", controller.GetCategoryList)
+		public.GET("/:id", controller.GetCategory)
+		categories.Use(
+			middleware.AuthMiddleware(config),
+			middleware.RateLimitMiddleware(config, config.RateLimit.Authenticated()),
+			middleware.BodyLimitMiddleware(config.Security.MaxBodySize),
+		)
 		{
-			categories.POST("/create", controller.CreateCategory)
+			categories.POST("/create", middleware.IdempotencyMiddleware(config), controller.CreateCategory)
 			categories.PUT("/update", controller.UpdateCategory)
+			categories.PATCH("/:id", controller.PatchCategory)
+		}
+	}
+}
+
+func Media(r *gin.RouterGroup, controller controller.MediaController, config *controller.Config) {
+	media := r.Group("/media")
+	{
+		public := media.Group("", middleware.RateLimitMiddleware(config, config.RateLimit.Public()))
+		public.GET("/files/*key", controller.GetMediaFile)
+		media.Use(
+			middleware.AuthMiddleware(config),
+			middleware.RateLimitMiddleware(config, config.RateLimit.Authenticated()),
+		)
+		{
+			media.POST("/upload",
+				middleware.BodyLimitMiddleware(config.Media.UploadLimit()),
+				middleware.IdempotencyMiddleware(config),
+				controller.UploadMedia,
+			)
+			media.GET("", controller.GetMediaList)
+			media.GET("/:id", controller.GetMedia)
+			media.DELETE("/:id", controller.DeleteMedia)
 		}
 	}
 }
@@ -66,11 +120,25 @@ func Category(r *gin.RouterGroup, controller controller.CategoryController, conf
 func Authorization(r *gin.RouterGroup, controller controller.AuthorizationController, config *controller.Config) {
 	auths := r.Group("/auths")
 	{
-		auths.POST("/login", controller.Login)
-		auths.Use(middleware.AuthMiddleware(config))
+		auths.POST("/login",
+			middleware.RateLimitMiddleware(config, config.RateLimit.Login()),
+			middleware.BodyLimitMiddleware(config.Security.MaxBodySize),
+			controller.Login,
+		)
+		auths.Use(
+			middleware.AuthMiddleware(config),
+			middleware.RateLimitMiddleware(config, config.RateLimit.Authenticated()),
+		)
 		{
 			auths.GET("/logout", controller.Logout)
 		}
 	}
 
 }
+
+func I18n(r *gin.RouterGroup, controller controller.I18nController, config *controller.Config) {
+	i18n := r.Group("/i18n", middleware.RateLimitMiddleware(config, config.RateLimit.Public()))
+	{
+		i18n.GET("/:locale", controller.GetCatalog)
+	}
+}
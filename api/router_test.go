@@ -0,0 +1,928 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/cache"
+	"github.com/michaelwp/goblog/config"
+	"github.com/michaelwp/goblog/entities"
+	"github.com/michaelwp/goblog/i18n"
+	"github.com/michaelwp/goblog/middleware"
+	"github.com/michaelwp/goblog/model"
+	"github.com/michaelwp/goblog/storage"
+	"github.com/michaelwp/goblog/tool"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	TEST_PASSWORD    = "secret-password"
+	TEST_ADMIN_EMAIL = "admin@goblog.test"
+	TEST_USER_EMAIL  = "writer@goblog.test"
+)
+
+type testServer struct {
+	router     *gin.Engine
+	config     *controller.Config
+	repository *model.MemoryRepository
+}
+
+type testResponse struct {
+	Status    string          `json:"status"`
+	Code      string          `json:"code"`
+	Message   string          `json:"message"`
+	Translate string          `json:"translate"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// newTestServer builds the router of api.NewRouter on the in-memory models and
+// cache, with Redis (sessions, rate limits, idempotency) served by miniredis.
+// Postgres is only used by /readyz and points at a closed port.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	t.Setenv("JWT_SIGNING_KEY", "test-signing-key")
+	t.Setenv("POSTGRES_DB_HOST", "127.0.0.1")
+	t.Setenv("POSTGRES_DB_PORT", "1")
+	t.Setenv("POSTGRES_DB_USER", "goblog")
+	t.Setenv("POSTGRES_DB_NAME", "goblog")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	appConfig, err := config.Load(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	postgres, err := sql.Open("postgres", "postgres://goblog@127.0.0.1:1/goblog?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, err := i18n.LoadDir("", appConfig.I18n.DefaultLocale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redisServer := miniredis.RunT(t)
+	repository := model.NewMemoryRepository()
+
+	files, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testConfig := &controller.Config{
+		Postgres:      postgres,
+		ArticleModel:  repository,
+		UserModel:     repository,
+		CategoryModel: repository,
+		Transactor:    repository,
+		MediaModel:    repository,
+		Storage:       files,
+		Media:         &appConfig.Media,
+		RedisClient:   redis.NewClient(&redis.Options{Addr: redisServer.Addr()}),
+		JwtSigningKey: []byte(appConfig.JwtSigningKey),
+		RateLimit:     &appConfig.RateLimit,
+		CORS:          &appConfig.CORS,
+		Security:      &appConfig.Security,
+		Idempotency:   &appConfig.Idempotency,
+		Auth:          &appConfig.Auth,
+		I18n:          catalog,
+	}
+	testConfig.Cache = cache.NewMemoryCache(appConfig.Cache.LocalSize, appConfig.Cache.StaleTTL, testConfig.Go)
+	t.Cleanup(func() {
+		_ = testConfig.Close()
+	})
+
+	router := gin.New()
+	NewRouter(router, testConfig)
+
+	server := &testServer{
+		router:     router,
+		config:     testConfig,
+		repository: repository,
+	}
+
+	server.createUser(t, "admin", TEST_ADMIN_EMAIL, true)
+	server.createUser(t, "writer", TEST_USER_EMAIL, false)
+
+	return server
+}
+
+func (s *testServer) createUser(t *testing.T, name, email string, admin bool) {
+	t.Helper()
+
+	hash, err := tool.GenerateHash([]byte(TEST_PASSWORD))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.repository.CreateUser(context.Background(), &entities.User{
+		Name:     name,
+		Email:    email,
+		Password: string(hash),
+		Admin:    entities.Status(admin),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (s *testServer) userId(t *testing.T, email string) int64 {
+	t.Helper()
+
+	user, err := s.repository.FindUser(context.Background(), &model.Where{
+		Parameter: "WHERE email=$1",
+		Values:    []any{email},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user.Id
+}
+
+// request sends body as JSON, or as is when it already is a string, and
+// decodes the standard response envelope when there is one.
+func (s *testServer) request(t *testing.T, method, path, token string, body any, headers map[string]string) (
+	*httptest.ResponseRecorder, *testResponse) {
+
+	t.Helper()
+
+	var payload []byte
+	switch typed := body.(type) {
+	case nil:
+	case string:
+		payload = []byte(typed)
+	default:
+		var err error
+		payload, err = json.Marshal(typed)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+
+	response := new(testResponse)
+	if recorder.Body.Len() > 0 {
+		_ = json.Unmarshal(recorder.Body.Bytes(), response)
+	}
+
+	return recorder, response
+}
+
+func (s *testServer) login(t *testing.T, email string) string {
+	t.Helper()
+
+	recorder, response := s.request(t, http.MethodPost, "/api/v1/auths/login", "", map[string]string{
+		"email":    email,
+		"password": TEST_PASSWORD,
+	}, nil)
+	expectStatus(t, recorder, http.StatusAccepted)
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	decode(t, response, &data)
+
+	return data.Token
+}
+
+func expectStatus(t *testing.T, recorder *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if recorder.Code != status {
+		t.Fatalf("status = %d, want %d, body: %s", recorder.Code, status, recorder.Body.String())
+	}
+}
+
+func expectCode(t *testing.T, response *testResponse, code string) {
+	t.Helper()
+
+	if response.Code != code {
+		t.Fatalf("code = %q, want %q", response.Code, code)
+	}
+}
+
+func decode(t *testing.T, response *testResponse, dest any) {
+	t.Helper()
+
+	if err := json.Unmarshal(response.Data, dest); err != nil {
+		t.Fatalf("decode data %s: %v", response.Data, err)
+	}
+}
+
+// upload sends content as the multipart file of the media upload.
+func (s *testServer) upload(t *testing.T, token, fileName string, content []byte) (
+	*httptest.ResponseRecorder, *testResponse) {
+
+	t.Helper()
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	if content != nil {
+		part, err := writer.CreateFormFile(controller.MEDIA_FORM_FIELD, fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = part.Write(content)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return s.request(t, http.MethodPost, "/api/v1/media/upload", token, body.String(),
+		map[string]string{"Content-Type": writer.FormDataContentType()})
+}
+
+func ifMatch(version int64) map[string]string {
+	return map[string]string{"If-Match": controller.ETag(version)}
+}
+
+func TestHealthRoutes(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder, response := server.request(t, http.MethodGet, "/ping", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	if response.Translate != "hello.from.GoBlog" {
+		t.Fatalf("translate = %q", response.Translate)
+	}
+
+	recorder, _ = server.request(t, http.MethodGet, "/healthz", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, response = server.request(t, http.MethodGet, "/readyz", "", nil, nil)
+	expectStatus(t, recorder, http.StatusServiceUnavailable)
+
+	var dependencies map[string]*controller.DependencyStatus
+	decode(t, response, &dependencies)
+	if dependencies["postgres"].Status != controller.DEPENDENCY_DOWN || dependencies["redis"].Status != controller.DEPENDENCY_UP {
+		t.Fatalf("dependencies = postgres %s, redis %s", dependencies["postgres"].Status, dependencies["redis"].Status)
+	}
+
+	recorder, _ = server.request(t, http.MethodGet, "/metrics", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	if !bytes.Contains(recorder.Body.Bytes(), []byte("goblog_http_requests_total")) {
+		t.Fatal("metrics do not expose goblog_http_requests_total")
+	}
+}
+
+func TestAuthorizationRoutes(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder, response := server.request(t, http.MethodPost, "/api/v1/auths/login", "", map[string]string{
+		"email":    TEST_USER_EMAIL,
+		"password": "wrong-password",
+	}, nil)
+	expectStatus(t, recorder, http.StatusUnauthorized)
+	expectCode(t, response, controller.ErrInvalidCredentials.Code)
+
+	recorder, response = server.request(t, http.MethodPost, "/api/v1/auths/login", "", "{", nil)
+	expectStatus(t, recorder, http.StatusBadRequest)
+	expectCode(t, response, controller.ErrInvalidBody.Code)
+
+	token := server.login(t, TEST_USER_EMAIL)
+
+	recorder, _ = server.request(t, http.MethodGet, "/api/v1/auths/logout", "", nil, nil)
+	expectStatus(t, recorder, http.StatusUnauthorized)
+
+	recorder, _ = server.request(t, http.MethodGet, "/api/v1/auths/logout", token, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	// the session is gone, the still valid token is refused
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/auths/logout", token, nil, nil)
+	expectStatus(t, recorder, http.StatusUnauthorized)
+	expectCode(t, response, controller.ErrUnauthorized.Code)
+}
+
+func TestUserRoutes(t *testing.T) {
+	server := newTestServer(t)
+	adminToken := server.login(t, TEST_ADMIN_EMAIL)
+	writerToken := server.login(t, TEST_USER_EMAIL)
+	writerId := server.userId(t, TEST_USER_EMAIL)
+	adminId := server.userId(t, TEST_ADMIN_EMAIL)
+
+	recorder, _ := server.request(t, http.MethodGet, "/api/v1/users", "", nil, nil)
+	expectStatus(t, recorder, http.StatusUnauthorized)
+
+	newUser := map[string]string{
+		"name":     "Reader",
+		"email":    "reader@goblog.test",
+		"password": TEST_PASSWORD,
+	}
+
+	recorder, _ = server.request(t, http.MethodPost, "/api/v1/users/create", adminToken, newUser, nil)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	recorder, response := server.request(t, http.MethodPost, "/api/v1/users/create", adminToken, newUser, nil)
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrEmailExists.Code)
+
+	newUser["email"] = "Reader@GoBlog.test"
+	recorder, response = server.request(t, http.MethodPost, "/api/v1/users/create", adminToken, newUser, nil)
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrEmailExists.Code)
+
+	recorder, response = server.request(t, http.MethodPost, "/api/v1/users/create", adminToken,
+		map[string]string{"name": "Nobody", "email": "not-an-email", "password": "short"}, nil)
+	expectStatus(t, recorder, http.StatusUnprocessableEntity)
+	expectCode(t, response, controller.ErrValidation.Code)
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/users", writerToken, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var users []*entities.User
+	decode(t, response, &users)
+	if len(users) != 3 {
+		t.Fatalf("users = %d, want 3", len(users))
+	}
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/users/"+strconv.FormatInt(writerId, 10), writerToken, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	etag := recorder.Header().Get("ETag")
+	if etag != controller.ETag(1) {
+		t.Fatalf("ETag = %q", etag)
+	}
+
+	recorder, _ = server.request(t, http.MethodGet, "/api/v1/users/"+strconv.FormatInt(writerId, 10), writerToken, nil,
+		map[string]string{"If-None-Match": etag})
+	expectStatus(t, recorder, http.StatusNotModified)
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/users/999", writerToken, nil, nil)
+	expectStatus(t, recorder, http.StatusNotFound)
+	expectCode(t, response, controller.ErrUserNotFound.Code)
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/users/abc", writerToken, nil, nil)
+	expectStatus(t, recorder, http.StatusBadRequest)
+	expectCode(t, response, controller.ErrInvalidId.Code)
+
+	recorder, _ = server.request(t, http.MethodPut, "/api/v1/users/update", writerToken, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	writerPath := "/api/v1/users/" + strconv.FormatInt(writerId, 10)
+	patch := `{"page": "https://writer.goblog.test"}`
+
+	recorder, response = server.request(t, http.MethodPatch, writerPath, writerToken, patch, nil)
+	expectStatus(t, recorder, http.StatusPreconditionRequired)
+	expectCode(t, response, controller.ErrPreconditionRequired.Code)
+
+	recorder, response = server.request(t, http.MethodPatch, writerPath, writerToken, patch, ifMatch(1))
+	expectStatus(t, recorder, http.StatusOK)
+
+	var patched entities.User
+	decode(t, response, &patched)
+	if patched.Page == nil || *patched.Page != "https://writer.goblog.test" || patched.Password != "" {
+		t.Fatalf("patched user = %+v", patched)
+	}
+
+	recorder, response = server.request(t, http.MethodPatch, writerPath, writerToken, patch, ifMatch(1))
+	expectStatus(t, recorder, http.StatusPreconditionFailed)
+	expectCode(t, response, controller.ErrPreconditionFailed.Code)
+
+	recorder, response = server.request(t, http.MethodPatch, writerPath, writerToken,
+		`{"email": "`+TEST_ADMIN_EMAIL+`"}`, ifMatch(2))
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrEmailExists.Code)
+
+	recorder, response = server.request(t, http.MethodPatch, writerPath, writerToken,
+		`{"email": "`+strings.ToUpper(TEST_ADMIN_EMAIL)+`"}`, ifMatch(2))
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrEmailExists.Code)
+
+	recorder, response = server.request(t, http.MethodPatch, writerPath, writerToken,
+		`{"email": "Writer@GoBlog.test"}`, ifMatch(2))
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, response, &patched)
+	if patched.Email != TEST_USER_EMAIL {
+		t.Fatalf("patched email = %q, want %q", patched.Email, TEST_USER_EMAIL)
+	}
+
+	recorder, response = server.request(t, http.MethodPatch, "/api/v1/users/"+strconv.FormatInt(adminId, 10), writerToken,
+		patch, ifMatch(1))
+	expectStatus(t, recorder, http.StatusForbidden)
+	expectCode(t, response, controller.ErrForbidden.Code)
+
+	// a null or empty password is not a valid patch
+	for _, passwordPatch := range []string{`{"password": null}`, `{"password": ""}`} {
+		recorder, response = server.request(t, http.MethodPatch, writerPath, writerToken, passwordPatch, ifMatch(3))
+		expectStatus(t, recorder, http.StatusUnprocessableEntity)
+		expectCode(t, response, controller.ErrValidation.Code)
+	}
+
+	// admins may patch anyone, the name is lower-cased like on create
+	recorder, response = server.request(t, http.MethodPatch, writerPath, adminToken, `{"name": "Editor"}`, ifMatch(3))
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, response, &patched)
+	if patched.Name != "editor" {
+		t.Fatalf("patched name = %q, want %q", patched.Name, "editor")
+	}
+}
+
+func TestCategoryRoutes(t *testing.T) {
+	server := newTestServer(t)
+	token := server.login(t, TEST_USER_EMAIL)
+
+	recorder, _ := server.request(t, http.MethodPost, "/api/v1/categories/create", "", map[string]string{"name": "Go"}, nil)
+	expectStatus(t, recorder, http.StatusUnauthorized)
+
+	recorder, _ = server.request(t, http.MethodPost, "/api/v1/categories/create", token, map[string]string{"name": "Go"}, nil)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	recorder, response := server.request(t, http.MethodPost, "/api/v1/categories/create", token,
+		map[string]string{"name": "GO"}, nil)
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrCategoryExists.Code)
+
+	recorder, _ = server.request(t, http.MethodPost, "/api/v1/categories/create", token, map[string]string{"name": "Rust"}, nil)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/categories", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var categories []*entities.Category
+	decode(t, response, &categories)
+	if len(categories) != 2 || categories[0].Name != "go" {
+		t.Fatalf("categories = %+v", categories)
+	}
+
+	categoryPath := "/api/v1/categories/" + strconv.FormatInt(categories[0].Id, 10)
+
+	recorder, response = server.request(t, http.MethodGet, categoryPath, "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/categories/999", "", nil, nil)
+	expectStatus(t, recorder, http.StatusNotFound)
+	expectCode(t, response, controller.ErrCategoryNotFound.Code)
+
+	recorder, _ = server.request(t, http.MethodPut, "/api/v1/categories/update", token, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, response = server.request(t, http.MethodPatch, categoryPath, token, `{"name": "rust"}`, ifMatch(1))
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrCategoryExists.Code)
+
+	recorder, response = server.request(t, http.MethodPatch, categoryPath, token, `{"name": "Golang"}`, ifMatch(1))
+	expectStatus(t, recorder, http.StatusOK)
+
+	var patched entities.Category
+	decode(t, response, &patched)
+	if patched.Name != "golang" || recorder.Header().Get("ETag") != controller.ETag(2) {
+		t.Fatalf("patched category = %+v, ETag %q", patched, recorder.Header().Get("ETag"))
+	}
+
+	recorder, response = server.request(t, http.MethodPatch, categoryPath, token, `{"unknown": 1}`, ifMatch(2))
+	expectStatus(t, recorder, http.StatusUnprocessableEntity)
+	expectCode(t, response, controller.ErrValidation.Code)
+}
+
+func TestCreateIsIdempotent(t *testing.T) {
+	server := newTestServer(t)
+	token := server.login(t, TEST_USER_EMAIL)
+	headers := map[string]string{"Idempotency-Key": "create-go"}
+
+	recorder, _ := server.request(t, http.MethodPost, "/api/v1/categories/create", token, map[string]string{"name": "Go"}, headers)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	recorder, _ = server.request(t, http.MethodPost, "/api/v1/categories/create", token, map[string]string{"name": "Go"}, headers)
+	expectStatus(t, recorder, http.StatusCreated)
+	if recorder.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("second request was not replayed")
+	}
+
+	recorder, response := server.request(t, http.MethodPost, "/api/v1/categories/create", token,
+		map[string]string{"name": "Rust"}, headers)
+	expectStatus(t, recorder, http.StatusUnprocessableEntity)
+	expectCode(t, response, controller.ErrIdempotencyKeyReused.Code)
+}
+
+func TestConcurrentCreateConflicts(t *testing.T) {
+	server := newTestServer(t)
+	token := server.login(t, TEST_USER_EMAIL)
+
+	const requests = 8
+	statuses := make([]int, requests)
+
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorder, _ := server.request(t, http.MethodPost, "/api/v1/categories/create", token,
+				map[string]string{"name": "Go"}, nil)
+			statuses[i] = recorder.Code
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Fatalf("statuses = %v", statuses)
+		}
+	}
+
+	if created != 1 {
+		t.Fatalf("statuses = %v, want exactly one %d", statuses, http.StatusCreated)
+	}
+}
+
+// conflictingTransactor fails every transaction as PostgreSQL does when a
+// concurrent insert of the same unique value committed first.
+type conflictingTransactor struct{}
+
+func (conflictingTransactor) Transaction(context.Context, func(ctx context.Context, work *model.UnitOfWork) error) error {
+	return fmt.Errorf("commit: %w", &pq.Error{Code: model.PG_UNIQUE_VIOLATION})
+}
+
+func TestUniqueViolationsAreConflicts(t *testing.T) {
+	server := newTestServer(t)
+	token := server.login(t, TEST_ADMIN_EMAIL)
+	server.config.Transactor = conflictingTransactor{}
+
+	recorder, response := server.request(t, http.MethodPost, "/api/v1/users/create", token, map[string]string{
+		"name":     "Reader",
+		"email":    "reader@goblog.test",
+		"password": TEST_PASSWORD,
+	}, nil)
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrEmailExists.Code)
+
+	recorder, response = server.request(t, http.MethodPost, "/api/v1/categories/create", token,
+		map[string]string{"name": "Go"}, nil)
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrCategoryExists.Code)
+}
+
+func TestReadYourWrites(t *testing.T) {
+	server := newTestServer(t)
+	server.config.ReadYourWrites = time.Minute
+	token := server.login(t, TEST_USER_EMAIL)
+	key := middleware.READ_YOUR_WRITES_PREFIX + strconv.FormatInt(server.userId(t, TEST_USER_EMAIL), 10)
+
+	recorder, _ := server.request(t, http.MethodGet, "/api/v1/categories", token, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	if sticky, _ := server.config.RedisClient.Exists(context.Background(), key).Result(); sticky != 0 {
+		t.Fatal("a read made the user sticky")
+	}
+
+	recorder, _ = server.request(t, http.MethodPost, "/api/v1/categories/create", token, map[string]string{}, nil)
+	expectStatus(t, recorder, http.StatusUnprocessableEntity)
+	if sticky, _ := server.config.RedisClient.Exists(context.Background(), key).Result(); sticky != 0 {
+		t.Fatal("a failed write made the user sticky")
+	}
+
+	recorder, _ = server.request(t, http.MethodPost, "/api/v1/categories/create", token, map[string]string{"name": "Go"}, nil)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	ttl, err := server.config.RedisClient.TTL(context.Background(), key).Result()
+	if err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("sticky TTL = %v, %v", ttl, err)
+	}
+}
+
+func TestMediaRoutes(t *testing.T) {
+	server := newTestServer(t)
+	token := server.login(t, TEST_USER_EMAIL)
+	writerId := server.userId(t, TEST_USER_EMAIL)
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+	recorder, _ := server.upload(t, "", "avatar.png", png)
+	expectStatus(t, recorder, http.StatusUnauthorized)
+
+	recorder, response := server.upload(t, token, `C:\photos\avatar.png`, png)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	var media entities.Media
+	decode(t, response, &media)
+	if media.ContentType != "image/png" || media.FileName != "avatar.png" || media.Size != int64(len(png)) ||
+		media.OwnerId != writerId {
+		t.Fatalf("media = %+v", media)
+	}
+
+	recorder, response = server.upload(t, token, "copy.png", png)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var existing entities.Media
+	decode(t, response, &existing)
+	if existing.Id != media.Id {
+		t.Fatalf("duplicate upload id = %d, want %d", existing.Id, media.Id)
+	}
+
+	recorder, response = server.upload(t, token, "avatar.png", []byte("just some text"))
+	expectStatus(t, recorder, http.StatusUnsupportedMediaType)
+	expectCode(t, response, controller.ErrMediaType.Code)
+
+	recorder, response = server.upload(t, token, "", nil)
+	expectStatus(t, recorder, http.StatusUnprocessableEntity)
+	expectCode(t, response, controller.ErrValidation.Code)
+
+	recorder, response = server.upload(t, token, "large.png", append(png, make([]byte, server.config.Media.MaxSize)...))
+	expectStatus(t, recorder, http.StatusRequestEntityTooLarge)
+	expectCode(t, response, controller.ErrBodyTooLarge.Code)
+
+	recorder, _ = server.request(t, http.MethodGet, media.Url, "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	if !bytes.Equal(recorder.Body.Bytes(), png) || recorder.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("file = %q, content type %q", recorder.Body.Bytes(), recorder.Header().Get("Content-Type"))
+	}
+
+	recorder, _ = server.request(t, http.MethodGet, media.Url, "", nil,
+		map[string]string{"If-None-Match": recorder.Header().Get("ETag")})
+	expectStatus(t, recorder, http.StatusNotModified)
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/media", token, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var mediaList []*entities.Media
+	decode(t, response, &mediaList)
+	if len(mediaList) != 1 || mediaList[0].Id != media.Id {
+		t.Fatalf("media list = %+v", mediaList)
+	}
+
+	mediaPath := "/api/v1/media/" + strconv.FormatInt(media.Id, 10)
+	writerPath := "/api/v1/users/" + strconv.FormatInt(writerId, 10)
+
+	// another user's media is not disclosed, admins see all
+	server.createUser(t, "reader", "reader@goblog.test", false)
+	recorder, response = server.request(t, http.MethodGet, mediaPath, server.login(t, "reader@goblog.test"), nil, nil)
+	expectStatus(t, recorder, http.StatusNotFound)
+	expectCode(t, response, controller.ErrMediaNotFound.Code)
+
+	recorder, _ = server.request(t, http.MethodGet, mediaPath, server.login(t, TEST_ADMIN_EMAIL), nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, _ = server.request(t, http.MethodPatch, writerPath, token, `{"avatar": "`+media.Url+`"}`, ifMatch(1))
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, response = server.request(t, http.MethodGet, mediaPath, token, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, response, &media)
+	if media.Usage != 1 {
+		t.Fatalf("usage = %d, want 1", media.Usage)
+	}
+
+	recorder, response = server.request(t, http.MethodDelete, mediaPath, token, nil, nil)
+	expectStatus(t, recorder, http.StatusConflict)
+	expectCode(t, response, controller.ErrMediaInUse.Code)
+
+	recorder, _ = server.request(t, http.MethodPatch, writerPath, token, `{"avatar": "https://cdn.goblog.test/a.png"}`,
+		ifMatch(2))
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, _ = server.request(t, http.MethodDelete, mediaPath, token, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, response = server.request(t, http.MethodGet, media.Url, "", nil, nil)
+	expectStatus(t, recorder, http.StatusNotFound)
+	expectCode(t, response, controller.ErrMediaNotFound.Code)
+}
+
+// racingMediaModel acts as if another request uploaded or deleted the same
+// media between the reads and the writes of the handler.
+type racingMediaModel struct {
+	model.MediaModel
+	missChecksum bool
+}
+
+// FindMedia misses the lookup by checksum once, as if the other upload was
+// not inserted yet.
+func (r *racingMediaModel) FindMedia(ctx context.Context, where *model.Where) (*entities.Media, error) {
+	if r.missChecksum && strings.Contains(where.Parameter, "checksum") {
+		r.missChecksum = false
+		return nil, sql.ErrNoRows
+	}
+
+	return r.MediaModel.FindMedia(ctx, where)
+}
+
+// DeleteMedia deletes the media twice, the first time for the other request.
+func (r *racingMediaModel) DeleteMedia(ctx context.Context, mediaId int64) (sql.Result, error) {
+	if _, err := r.MediaModel.DeleteMedia(ctx, mediaId); err != nil {
+		return nil, err
+	}
+
+	return r.MediaModel.DeleteMedia(ctx, mediaId)
+}
+
+func TestMediaRaces(t *testing.T) {
+	server := newTestServer(t)
+	token := server.login(t, TEST_USER_EMAIL)
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{1}, 64)...)
+
+	recorder, response := server.upload(t, token, "same.png", png)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	var media entities.Media
+	decode(t, response, &media)
+
+	racing := &racingMediaModel{MediaModel: server.config.MediaModel, missChecksum: true}
+	server.config.MediaModel = racing
+
+	// the unique index catches what the lookup missed
+	recorder, response = server.upload(t, token, "same.png", png)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var existing entities.Media
+	decode(t, response, &existing)
+	if existing.Id != media.Id || racing.missChecksum {
+		t.Fatalf("concurrent upload id = %d, want %d", existing.Id, media.Id)
+	}
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/media", token, nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var mediaList []*entities.Media
+	decode(t, response, &mediaList)
+	if len(mediaList) != 1 {
+		t.Fatalf("media list = %+v, want one media", mediaList)
+	}
+
+	recorder, response = server.request(t, http.MethodDelete, "/api/v1/media/"+strconv.FormatInt(media.Id, 10), token,
+		nil, nil)
+	expectStatus(t, recorder, http.StatusNotFound)
+	expectCode(t, response, controller.ErrMediaNotFound.Code)
+}
+
+func TestArticleRoutes(t *testing.T) {
+	server := newTestServer(t)
+	token := server.login(t, TEST_USER_EMAIL)
+
+	recorder, _ := server.request(t, http.MethodPost, "/api/v1/categories/create", token, map[string]string{"name": "Go"}, nil)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	article := map[string]any{
+		"category_id": 1,
+		"title":       "Hello",
+		"content":     "First article",
+		"tags":        "Go, Testing",
+	}
+
+	recorder, _ = server.request(t, http.MethodPost, "/api/v1/articles/create", "", article, nil)
+	expectStatus(t, recorder, http.StatusUnauthorized)
+
+	recorder, response := server.request(t, http.MethodPost, "/api/v1/articles/create", token,
+		map[string]any{"category_id": 42, "title": "Lost", "content": "No category"}, nil)
+	expectStatus(t, recorder, http.StatusUnprocessableEntity)
+	expectCode(t, response, controller.ErrValidation.Code)
+
+	recorder, _ = server.request(t, http.MethodPost, "/api/v1/articles/create", token, article, nil)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/articles", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var articles []*entities.Article
+	decode(t, response, &articles)
+	if len(articles) != 1 || articles[0].Title != "Hello" {
+		t.Fatalf("articles = %+v", articles)
+	}
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/articles/1", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var found entities.Article
+	decode(t, response, &found)
+	if found.Tags == nil || *found.Tags != "go,testing" {
+		t.Fatalf("article = %+v", found)
+	}
+
+	etag := recorder.Header().Get("ETag")
+	recorder, _ = server.request(t, http.MethodGet, "/api/v1/articles/1", "", nil,
+		map[string]string{"If-None-Match": etag})
+	expectStatus(t, recorder, http.StatusNotModified)
+
+	// renaming the category changes the embedded category name, not the article version
+	recorder, _ = server.request(t, http.MethodPatch, "/api/v1/categories/1", token, `{"name": "Golang"}`, ifMatch(1))
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, _ = server.request(t, http.MethodGet, "/api/v1/articles/1", "", nil,
+		map[string]string{"If-None-Match": etag})
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, response = server.request(t, http.MethodPatch, "/api/v1/articles/1", token, `{"title": "Stale"}`,
+		map[string]string{"If-Match": etag})
+	expectStatus(t, recorder, http.StatusPreconditionFailed)
+	expectCode(t, response, controller.ErrPreconditionFailed.Code)
+
+	recorder, _ = server.request(t, http.MethodGet, "/api/v1/articles/1", "", nil, nil)
+	etag = recorder.Header().Get("ETag")
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/articles/999", "", nil, nil)
+	expectStatus(t, recorder, http.StatusNotFound)
+	expectCode(t, response, controller.ErrArticleNotFound.Code)
+
+	update := map[string]any{
+		"id":          1,
+		"category_id": 1,
+		"title":       "Hello again",
+		"content":     "Updated article",
+	}
+
+	recorder, response = server.request(t, http.MethodPut, "/api/v1/articles/update", token, update, nil)
+	expectStatus(t, recorder, http.StatusPreconditionRequired)
+
+	recorder, _ = server.request(t, http.MethodPut, "/api/v1/articles/update", token, update,
+		map[string]string{"If-Match": etag})
+	expectStatus(t, recorder, http.StatusOK)
+	updatedETag := recorder.Header().Get("ETag")
+
+	// the update evicted the cached article
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/articles/1", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, response, &found)
+	if found.Title != "Hello again" || found.Version != 2 || recorder.Header().Get("ETag") != updatedETag {
+		t.Fatalf("article = %+v, ETag %q, want %q", found, recorder.Header().Get("ETag"), updatedETag)
+	}
+
+	recorder, response = server.request(t, http.MethodPatch, "/api/v1/articles/1", token, `{"title": "Patched"}`,
+		map[string]string{"If-Match": etag})
+	expectStatus(t, recorder, http.StatusPreconditionFailed)
+	expectCode(t, response, controller.ErrPreconditionFailed.Code)
+
+	recorder, response = server.request(t, http.MethodPatch, "/api/v1/articles/1", token,
+		`{"title": "Patched", "description": "Short"}`, map[string]string{"If-Match": updatedETag})
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, response, &found)
+	if found.Title != "Patched" || found.Description == nil || *found.Description != "Short" || found.Content != "Updated article" {
+		t.Fatalf("patched article = %+v", found)
+	}
+
+	patchedETag := recorder.Header().Get("ETag")
+	recorder, _ = server.request(t, http.MethodDelete, "/api/v1/articles/delete?id=1", token, nil,
+		map[string]string{"If-Match": updatedETag})
+	expectStatus(t, recorder, http.StatusPreconditionFailed)
+
+	recorder, _ = server.request(t, http.MethodDelete, "/api/v1/articles/delete?id=1", token, nil,
+		map[string]string{"If-Match": patchedETag})
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder, _ = server.request(t, http.MethodGet, "/api/v1/articles/1", "", nil, nil)
+	expectStatus(t, recorder, http.StatusNotFound)
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/articles", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, response, &articles)
+	if len(articles) != 0 {
+		t.Fatalf("articles after delete = %+v", articles)
+	}
+}
+
+func TestI18nRoute(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder, response := server.request(t, http.MethodGet, "/api/v1/i18n/id", "", nil, nil)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var messages map[string]string
+	decode(t, response, &messages)
+	if messages["unauthorized"] != "tidak memiliki akses" {
+		t.Fatalf("unauthorized = %q", messages["unauthorized"])
+	}
+
+	recorder, response = server.request(t, http.MethodGet, "/api/v1/i18n/xx", "", nil, nil)
+	expectStatus(t, recorder, http.StatusNotFound)
+	expectCode(t, response, controller.ErrLocaleNotFound.Code)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/michaelwp/goblog/cache"
+	"github.com/redis/go-redis/v9"
+	"log"
+)
+
+const CACHE_USAGE = "usage: goblog cache flush [-all]"
+
+// ARTICLE_CACHE_PATTERNS match the articleList and article:<id> keys and the
+// tag sets recording them.
+var ARTICLE_CACHE_PATTERNS = []string{cache.KEY_ARTICLE_LIST, cache.KEY_ARTICLE + "*", cache.TAG_PREFIX + "*"}
+
+// RunCache flushes the article caches in Redis and tells the local tier of the
+// running tiered instances to drop them too. The memory backend lives inside
+// each server process and cannot be flushed from here, restart the servers or
+// wait for the entries to expire.
+func RunCache(args []string) {
+	if len(args) < 1 || args[0] != "flush" {
+		log.Fatal(CACHE_USAGE)
+	}
+
+	flagSet := flag.NewFlagSet("cache flush", flag.ExitOnError)
+	all := flagSet.Bool("all", false, "flush the whole Redis database, including login sessions")
+	appConfig := LoadConfig(flagSet, args[1:])
+
+	client, err := SetupRedis(appConfig)
+	if err != nil {
+		log.Fatal("error connect to redis", err)
+	}
+
+	defer func() {
+		_ = client.Close()
+	}()
+
+	ctx := context.Background()
+
+	if *all {
+		err = client.FlushDB(ctx).Err()
+		if err != nil {
+			log.Fatal("error flush redis: ", err)
+		}
+
+		publishInvalidation(ctx, client, nil, true)
+		fmt.Println("redis database flushed")
+		return
+	}
+
+	var deleted []string
+	for _, pattern := range ARTICLE_CACHE_PATTERNS {
+		iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			err = client.Del(ctx, iter.Val()).Err()
+			if err != nil {
+				log.Fatal("error delete cache key: ", err)
+			}
+
+			deleted = append(deleted, iter.Val())
+		}
+
+		if err = iter.Err(); err != nil {
+			log.Fatal("error scan cache keys: ", err)
+		}
+	}
+
+	publishInvalidation(ctx, client, deleted, false)
+	fmt.Printf("%d cache key(s) deleted\n", len(deleted))
+}
+
+// publishInvalidation evicts the flushed keys, and every list through
+// TAG_ARTICLES, from the local tiers. A lost message only delays the eviction
+// until CACHE_LOCAL_TTL, so it is not fatal.
+func publishInvalidation(ctx context.Context, client *redis.Client, keys []string, purge bool) {
+	err := cache.PublishInvalidation(ctx, client, keys, []string{cache.TAG_ARTICLES}, purge)
+	if err != nil {
+		log.Println("error publish cache invalidation: ", err)
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/michaelwp/goblog/metrics"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling Redis while the breaker is open.
+var ErrCircuitOpen = errors.New("cache: circuit open")
+
+// Breaker stops calling Redis after threshold consecutive failures. Once
+// cooldown has passed a single probe is let through: its success closes the
+// breaker, its failure opens it for another cooldown.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Allow reports whether Redis may be called now.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// Record counts the result of a call allowed by Allow. A missing key or a
+// cancelled request says nothing about Redis and counts as a success.
+func (b *Breaker) Record(err error) {
+	if err != nil && (errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled)) {
+		err = nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if err == nil {
+		if b.failures >= b.threshold {
+			metrics.CacheCircuitOpen.Set(0)
+		}
+
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		metrics.CacheCircuitOpen.Set(1)
+	}
+}
+
+// Do calls fn unless the breaker is open and records its result.
+func (b *Breaker) Do(fn func() error) error {
+	if !b.Allow() {
+		return ErrCircuitOpen
+	}
+
+	err := fn()
+	b.Record(err)
+
+	return err
+}
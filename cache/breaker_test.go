@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBreakerOpensAndCloses(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	breaker := NewBreaker(2, cooldown)
+	failure := errors.New("connection refused")
+
+	calls := 0
+	call := func(err error) func() error {
+		return func() error {
+			calls++
+			return err
+		}
+	}
+
+	// a miss and a cancelled request say nothing about Redis
+	for _, err := range []error{redis.Nil, context.Canceled, failure} {
+		if got := breaker.Do(call(err)); !errors.Is(got, err) {
+			t.Fatalf("Do = %v, want %v", got, err)
+		}
+	}
+
+	if !breaker.Allow() {
+		t.Fatal("open after one failure")
+	}
+	breaker.Record(nil)
+
+	for range 2 {
+		_ = breaker.Do(call(failure))
+	}
+
+	calls = 0
+	if err := breaker.Do(call(nil)); !errors.Is(err, ErrCircuitOpen) || calls != 0 {
+		t.Fatalf("Do while open = %v after %d calls", err, calls)
+	}
+
+	time.Sleep(cooldown + 5*time.Millisecond)
+
+	// half-open: a single probe, the others are still rejected
+	if !breaker.Allow() {
+		t.Fatal("no probe after the cooldown")
+	}
+
+	if breaker.Allow() {
+		t.Fatal("second probe while the first one runs")
+	}
+
+	breaker.Record(failure)
+	if breaker.Allow() {
+		t.Fatal("closed by a failed probe")
+	}
+
+	time.Sleep(cooldown + 5*time.Millisecond)
+
+	if err := breaker.Do(call(nil)); err != nil {
+		t.Fatalf("probe = %v", err)
+	}
+
+	for range 3 {
+		if !breaker.Allow() {
+			t.Fatal("open after a successful probe")
+		}
+		breaker.Record(nil)
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/michaelwp/goblog/tool"
+	"strconv"
+	"time"
+)
+
+const (
+	KEY_ARTICLE_LIST = "articleList"
+	KEY_ARTICLE      = "article:"
+
+	// TAG_ARTICLES is carried by every key listing articles, any article
+	// mutation invalidates it.
+	TAG_ARTICLES = "articles"
+
+	// TAG_PREFIX namespaces the sets holding the keys of each tag.
+	TAG_PREFIX = "tag:"
+
+	// LOCK_PREFIX namespaces the locks serializing the loads of a key.
+	LOCK_PREFIX = "lock:"
+
+	// GENERATION_KEY counts invalidations, GENERATION_PREFIX namespaces the
+	// generation each tag was last invalidated at. A load started before that
+	// generation may have read data the invalidation was meant to evict, so
+	// its value is not stored. A tag's generation is kept for GENERATION_TTL,
+	// longer than any load runs.
+	GENERATION_KEY    = "generation"
+	GENERATION_PREFIX = "generation:"
+	GENERATION_TTL    = 1 * time.Hour
+	// UNCONDITIONAL is the generation of a value stored by Set, regardless of
+	// invalidations.
+	UNCONDITIONAL = -1
+
+	LOCK_POLL_INTERVAL = 50 * time.Millisecond
+
+	ARTICLE_TTL = 1 * time.Hour
+)
+
+func ArticleKey(articleId int64) string {
+	return KEY_ARTICLE + strconv.FormatInt(articleId, 10)
+}
+
+func ArticleTag(articleId int64) string {
+	return "article:" + strconv.FormatInt(articleId, 10)
+}
+
+func CategoryTag(categoryId int64) string {
+	return "category:" + strconv.FormatInt(categoryId, 10)
+}
+
+func UserTag(userId int64) string {
+	return "user:" + strconv.FormatInt(userId, 10)
+}
+
+// Cache is the backend the controllers store articles in. Values are JSON
+// encoded; keys carry tags so a mutation evicts every dependent key at once.
+type Cache interface {
+	// Get decodes the value of key into dest and reports whether it was found.
+	Get(ctx context.Context, key string, dest any) (hit bool, err error)
+	// Set stores value under key, fresh for ttl, tagged with tags.
+	Set(ctx context.Context, key string, value any, ttl time.Duration, tags ...string) error
+	// Fetch decodes the value of key into dest, calling load when it is
+	// missing. Errors of load are returned unchanged.
+	Fetch(ctx context.Context, key string, dest any, ttl time.Duration, load Loader) (hit bool, err error)
+	Delete(ctx context.Context, keys ...string) error
+	// InvalidateTags deletes every key stored with one of tags, best-effort.
+	// A load running meanwhile does not store its value under them again.
+	InvalidateTags(ctx context.Context, tags ...string)
+}
+
+// Options tune Fetch. An entry is served for StaleTTL after it expired while a
+// background worker refreshes it. LockTTL bounds how long a loader holds the
+// lock of a key and LockWait how long other instances wait for its value.
+// After BreakerThreshold consecutive Redis failures the cache is bypassed for
+// BreakerCooldown.
+type Options struct {
+	StaleTTL time.Duration
+	LockTTL  time.Duration
+	LockWait time.Duration
+
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// Loader produces the value of a missing or stale key and the tags it depends on.
+// It may run in the background, after the request that triggered it returned,
+// so it must only use the context it is given.
+type Loader func(ctx context.Context) (value any, tags []string, err error)
+
+// runner returns background, or one running fn in a plain goroutine when it is nil.
+func runner(background func(fn func(ctx context.Context))) func(fn func(ctx context.Context)) {
+	if background != nil {
+		return background
+	}
+
+	return func(fn func(ctx context.Context)) {
+		go fn(context.Background())
+	}
+}
+
+// logError logs a failed cache operation, except while the breaker is open
+// and every call fails the same way.
+func logError(action string, err error) {
+	if err != nil && !errors.Is(err, ErrCircuitOpen) {
+		tool.PrintLog(action, err)
+	}
+}
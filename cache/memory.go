@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"github.com/michaelwp/goblog/tool"
+	"golang.org/x/sync/singleflight"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process LRU cache holding at most size entries. It is
+// not shared between instances, so on its own it only suits a single instance.
+type MemoryCache struct {
+	size       int
+	staleTTL   time.Duration
+	background func(fn func(ctx context.Context))
+
+	mu sync.Mutex
+	// lru holds *memoryEntry, most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+	tags    map[string]map[string]bool
+
+	// generation counts invalidations. While loads are running, invalidated
+	// holds the generation each tag was last invalidated at, so a load that
+	// started before does not store what the invalidation evicted.
+	generation  int64
+	loading     int
+	invalidated map[string]int64
+
+	loads      singleflight.Group
+	refreshing sync.Map
+}
+
+type memoryEntry struct {
+	key        string
+	content    []byte
+	tags       []string
+	freshUntil time.Time
+	expiresAt  time.Time
+}
+
+// NewMemoryCache returns a cache serving expired entries for staleTTL while
+// background refreshes them.
+func NewMemoryCache(size int, staleTTL time.Duration, background func(fn func(ctx context.Context))) *MemoryCache {
+	return &MemoryCache{
+		size:        size,
+		staleTTL:    staleTTL,
+		background:  runner(background),
+		lru:         list.New(),
+		entries:     map[string]*list.Element{},
+		tags:        map[string]map[string]bool{},
+		invalidated: map[string]int64{},
+	}
+}
+
+func (m *MemoryCache) Get(_ context.Context, key string, dest any) (hit bool, err error) {
+	cached := m.get(key)
+	if cached == nil {
+		return false, nil
+	}
+
+	err = json.Unmarshal(cached.content, dest)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (m *MemoryCache) Set(_ context.Context, key string, value any, ttl time.Duration, tags ...string) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	m.set(key, content, ttl, tags, UNCONDITIONAL)
+	return nil
+}
+
+// Fetch coalesces concurrent misses of a key into one load and returns a stale
+// value as a hit while a single background worker refreshes it.
+func (m *MemoryCache) Fetch(ctx context.Context, key string, dest any, ttl time.Duration, load Loader) (
+	hit bool, err error) {
+
+	if cached := m.get(key); cached != nil {
+		if time.Now().After(cached.freshUntil) {
+			m.revalidate(key, ttl, load)
+		}
+
+		return true, json.Unmarshal(cached.content, dest)
+	}
+
+	// the load outlives a caller that gives up, the others still wait for it
+	loadCtx := context.WithoutCancel(ctx)
+	content, err, _ := m.loads.Do(key, func() (any, error) {
+		return m.load(loadCtx, key, ttl, load)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return false, json.Unmarshal(content.([]byte), dest)
+}
+
+func (m *MemoryCache) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := m.entries[key]; ok {
+			m.remove(element)
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryCache) InvalidateTags(_ context.Context, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.generation++
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			m.remove(m.entries[key])
+		}
+
+		if m.loading > 0 {
+			m.invalidated[tag] = m.generation
+		}
+	}
+}
+
+// Purge drops every entry.
+func (m *MemoryCache) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lru.Init()
+	clear(m.entries)
+	clear(m.tags)
+}
+
+// get returns the entry of key, fresh or stale, and marks it as recently used.
+func (m *MemoryCache) get(key string) *memoryEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+
+	cached := element.Value.(*memoryEntry)
+	if time.Now().After(cached.expiresAt) {
+		m.remove(element)
+		return nil
+	}
+
+	m.lru.MoveToFront(element)
+	return cached
+}
+
+// set stores the entry for ttl plus the stale window, evicting the least
+// recently used entries beyond size. It is dropped when one of tags was
+// invalidated after generation.
+func (m *MemoryCache) set(key string, content []byte, ttl time.Duration, tags []string, generation int64) {
+	now := time.Now()
+	cached := &memoryEntry{
+		key:        key,
+		content:    content,
+		tags:       tags,
+		freshUntil: now.Add(ttl),
+		expiresAt:  now.Add(ttl + m.staleTTL),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if generation != UNCONDITIONAL {
+		for _, tag := range tags {
+			if m.invalidated[tag] > generation {
+				return
+			}
+		}
+	}
+
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+
+	m.entries[key] = m.lru.PushFront(cached)
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = map[string]bool{}
+		}
+
+		m.tags[tag][key] = true
+	}
+
+	for m.lru.Len() > m.size {
+		m.remove(m.lru.Back())
+	}
+}
+
+// remove must be called with mu held.
+func (m *MemoryCache) remove(element *list.Element) {
+	cached := m.lru.Remove(element).(*memoryEntry)
+	delete(m.entries, cached.key)
+
+	for _, tag := range cached.tags {
+		delete(m.tags[tag], cached.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+func (m *MemoryCache) load(ctx context.Context, key string, ttl time.Duration, load Loader) ([]byte, error) {
+	generation := m.startLoad()
+	defer m.endLoad()
+
+	value, tags, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	m.set(key, content, ttl, tags, generation)
+	return content, nil
+}
+
+// startLoad returns the generation a load starting now must be stored at.
+// Every call must be followed by endLoad.
+func (m *MemoryCache) startLoad() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.loading++
+	return m.generation
+}
+
+// endLoad forgets the invalidations once no load could be older than them.
+func (m *MemoryCache) endLoad() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.loading--
+	if m.loading == 0 {
+		clear(m.invalidated)
+	}
+}
+
+// revalidate refreshes key in the background unless it is already refreshing.
+func (m *MemoryCache) revalidate(key string, ttl time.Duration, load Loader) {
+	if _, running := m.refreshing.LoadOrStore(key, true); running {
+		return
+	}
+
+	m.background(func(ctx context.Context) {
+		defer m.refreshing.Delete(key)
+
+		_, err := m.load(ctx, key, ttl, load)
+		if err != nil {
+			tool.PrintLog("cache refresh "+key, err)
+		}
+	})
+}
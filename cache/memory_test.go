@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheSkipsLoadsOlderThanInvalidation(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := NewMemoryCache(10, time.Minute, nil)
+
+	var value string
+	_, err := memoryCache.Fetch(ctx, "article:1", &value, time.Minute, mutatingLoader(memoryCache, "article:1", "old"))
+	if err != nil || value != "old" {
+		t.Fatalf("Fetch = %q, %v", value, err)
+	}
+
+	if hit, _ := memoryCache.Get(ctx, "article:1", &value); hit {
+		t.Fatal("a load older than the invalidation of its tag was stored")
+	}
+
+	if len(memoryCache.invalidated) != 0 {
+		t.Fatalf("invalidations kept after the last load: %v", memoryCache.invalidated)
+	}
+
+	_, err = memoryCache.Fetch(ctx, "article:1", &value, time.Minute, func(ctx context.Context) (any, []string, error) {
+		return "new", []string{"article:1"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hit, _ := memoryCache.Get(ctx, "article:1", &value); !hit || value != "new" {
+		t.Fatalf("Get after a clean load = %q, %v", value, hit)
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := NewMemoryCache(2, 0, nil)
+
+	for _, key := range []string{"a", "b"} {
+		if err := memoryCache.Set(ctx, key, key, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var value string
+	if hit, _ := memoryCache.Get(ctx, "a", &value); !hit {
+		t.Fatal("a missing before the size was reached")
+	}
+
+	if err := memoryCache.Set(ctx, "c", "c", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if hit, _ := memoryCache.Get(ctx, key, &value); hit != want {
+			t.Fatalf("%s cached = %v, want %v", key, hit, want)
+		}
+	}
+
+	if memoryCache.lru.Len() != 2 || len(memoryCache.entries) != 2 {
+		t.Fatalf("entries = %d, %d, want 2", memoryCache.lru.Len(), len(memoryCache.entries))
+	}
+}
+
+func TestMemoryCacheInvalidatesTags(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := NewMemoryCache(10, 0, nil)
+
+	tagged := map[string][]string{
+		"article:1":   {"article:1", "user:1"},
+		"article:2":   {"article:2", "user:1", "category:1"},
+		"article:3":   {"article:3", "category:1"},
+		"articleList": {TAG_ARTICLES},
+	}
+	for key, tags := range tagged {
+		if err := memoryCache.Set(ctx, key, key, time.Minute, tags...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	memoryCache.InvalidateTags(ctx, "user:1")
+
+	var value string
+	for key, want := range map[string]bool{"article:1": false, "article:2": false, "article:3": true, "articleList": true} {
+		if hit, _ := memoryCache.Get(ctx, key, &value); hit != want {
+			t.Fatalf("%s cached = %v, want %v", key, hit, want)
+		}
+	}
+
+	// the evicted keys are gone from the sets of their other tags
+	if keys := memoryCache.tags["category:1"]; len(keys) != 1 || !keys["article:3"] {
+		t.Fatalf("category:1 keys = %v", keys)
+	}
+
+	if _, ok := memoryCache.tags["article:1"]; ok {
+		t.Fatal("empty tag set kept")
+	}
+}
+
+func TestMemoryCacheRefreshesStaleOnce(t *testing.T) {
+	ctx := context.Background()
+
+	var refreshes sync.WaitGroup
+	background := func(fn func(ctx context.Context)) {
+		refreshes.Add(1)
+		go func() {
+			defer refreshes.Done()
+			fn(context.Background())
+		}()
+	}
+
+	memoryCache := NewMemoryCache(10, time.Minute, background)
+	if err := memoryCache.Set(ctx, "article:1", "old", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (any, []string, error) {
+		loads.Add(1)
+		<-release
+		return "new", nil, nil
+	}
+
+	for range 10 {
+		var value string
+		hit, err := memoryCache.Fetch(ctx, "article:1", &value, time.Minute, load)
+		if err != nil || !hit || value != "old" {
+			t.Fatalf("Fetch of a stale key = %q, %v, %v", value, hit, err)
+		}
+	}
+
+	close(release)
+	refreshes.Wait()
+
+	if loads.Load() != 1 {
+		t.Fatalf("loads = %d, want 1", loads.Load())
+	}
+
+	var value string
+	if hit, _ := memoryCache.Get(ctx, "article:1", &value); !hit || value != "new" {
+		t.Fatalf("Get after the refresh = %q, %v", value, hit)
+	}
+}
+
+func TestMemoryCacheCoalescesMisses(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := NewMemoryCache(10, 0, nil)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (any, []string, error) {
+		loads.Add(1)
+		<-release
+		return "loaded", nil, nil
+	}
+
+	var fetches sync.WaitGroup
+	for range 10 {
+		fetches.Add(1)
+		go func() {
+			defer fetches.Done()
+
+			var value string
+			_, err := memoryCache.Fetch(ctx, "article:1", &value, time.Minute, load)
+			if err != nil || value != "loaded" {
+				t.Errorf("Fetch = %q, %v", value, err)
+			}
+		}()
+	}
+
+	// let every Fetch join the load before it returns
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	fetches.Wait()
+
+	if loads.Load() != 1 {
+		t.Fatalf("loads = %d, want 1", loads.Load())
+	}
+}
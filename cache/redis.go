@@ -0,0 +1,400 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/michaelwp/goblog/tool"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"sync"
+	"time"
+)
+
+// invalidateScript deletes every key recorded in the given tag sets, then the
+// sets themselves, and records the new generation of each tag, in one atomic
+// step. KEYS are GENERATION_KEY followed by the set and generation key of
+// each tag. Keys are deleted in batches to stay below the Lua unpack limit.
+var invalidateScript = redis.NewScript(`
+	local generation = redis.call("INCR", KEYS[1])
+	local deleted = 0
+	for t = 2, #KEYS, 2 do
+		local keys = redis.call("SMEMBERS", KEYS[t])
+		for i = 1, #keys, 500 do
+			deleted = deleted + redis.call("DEL", unpack(keys, i, math.min(i + 499, #keys)))
+		end
+		redis.call("DEL", KEYS[t])
+		redis.call("SET", KEYS[t + 1], generation, "PX", ARGV[1])
+	end
+	return deleted
+`)
+
+// setScript stores ARGV[1] under KEYS[1] and records it in the tag sets, KEYS
+// continue with the set and generation key of each tag. A tag set never
+// expires before the keys it holds. When ARGV[3], the generation read before
+// the value was loaded, is not negative and a tag was invalidated since, the
+// value is dropped and 0 returned.
+var setScript = redis.NewScript(`
+	local ttl = tonumber(ARGV[2])
+	local generation = tonumber(ARGV[3])
+	if generation >= 0 then
+		for i = 3, #KEYS, 2 do
+			if tonumber(redis.call("GET", KEYS[i]) or "0") > generation then
+				return 0
+			end
+		end
+	end
+
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+	for i = 2, #KEYS, 2 do
+		redis.call("SADD", KEYS[i], KEYS[1])
+		if redis.call("PTTL", KEYS[i]) < ttl then
+			redis.call("PEXPIRE", KEYS[i], ttl)
+		end
+	end
+	return 1
+`)
+
+// unlockScript releases a lock only when it is still held with our token.
+var unlockScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+// entry is what is stored under a key: the JSON value, in Unix milliseconds
+// when it stops being fresh, and its tags for the local tier of TieredCache.
+type entry struct {
+	Value      json.RawMessage `json:"value"`
+	FreshUntil int64           `json:"fresh_until"`
+	Tags       []string        `json:"tags,omitempty"`
+}
+
+func (e *entry) fresh() bool {
+	return time.Now().UnixMilli() < e.FreshUntil
+}
+
+// RedisCache stores JSON values in Redis and records, for each tag, the set of
+// keys depending on it, so a mutation evicts every dependent key at once.
+// Redis is best-effort: when it fails Fetch falls back to the loader and
+// writes are dropped.
+type RedisCache struct {
+	client     *redis.Client
+	options    Options
+	breaker    *Breaker
+	background func(fn func(ctx context.Context))
+
+	// loads coalesces concurrent misses of a key within this instance,
+	// refreshing keeps a single background refresh per key.
+	loads      singleflight.Group
+	refreshing sync.Map
+
+	// pending holds the tags that could not be invalidated while Redis was unreachable.
+	pendingMu sync.Mutex
+	pending   map[string]bool
+}
+
+// NewRedisCache returns a cache running background refreshes with background,
+// typically controller.Config.Go so they are stopped on shutdown.
+func NewRedisCache(client *redis.Client, options Options, background func(fn func(ctx context.Context))) *RedisCache {
+	return &RedisCache{
+		client:     client,
+		options:    options,
+		breaker:    NewBreaker(options.BreakerThreshold, options.BreakerCooldown),
+		background: runner(background),
+		pending:    map[string]bool{},
+	}
+}
+
+// Get decodes the value of key into dest and reports whether it was found,
+// fresh or stale.
+func (r *RedisCache) Get(ctx context.Context, key string, dest any) (hit bool, err error) {
+	cached, err := r.get(ctx, key)
+	if err != nil || cached == nil {
+		return false, err
+	}
+
+	err = json.Unmarshal(cached.Value, dest)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Set stores value under key, fresh for ttl, and adds key to the set of every tag.
+func (r *RedisCache) Set(ctx context.Context, key string, value any, ttl time.Duration, tags ...string) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return r.set(ctx, key, content, ttl, tags, UNCONDITIONAL)
+}
+
+// Fetch decodes the value of key into dest, calling load when it is missing.
+// Concurrent misses share one load per instance and, through a Redis lock, one
+// load across instances. A stale value is returned as a hit while a single
+// background worker refreshes it. When Redis fails the value is loaded and not
+// cached. Errors of load are returned unchanged.
+func (r *RedisCache) Fetch(ctx context.Context, key string, dest any, ttl time.Duration, load Loader) (
+	hit bool, err error) {
+
+	cached, hit, err := r.fetch(ctx, key, ttl, load)
+	if err != nil {
+		return false, err
+	}
+
+	return hit, json.Unmarshal(cached.Value, dest)
+}
+
+func (r *RedisCache) fetch(ctx context.Context, key string, ttl time.Duration, load Loader) (
+	cached *entry, hit bool, err error) {
+
+	cached, err = r.get(ctx, key)
+	logError("cache get "+key, err)
+
+	if cached != nil {
+		if !cached.fresh() {
+			r.revalidate(key, ttl, load)
+		}
+
+		return cached, true, nil
+	}
+
+	// the load outlives a caller that gives up, the others still wait for it
+	loadCtx := context.WithoutCancel(ctx)
+	loaded, err, _ := r.loads.Do(key, func() (any, error) {
+		return r.loadLocked(loadCtx, key, ttl, load)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return loaded.(*entry), false, nil
+}
+
+func (r *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	return r.breaker.Do(func() error {
+		return r.client.Del(ctx, keys...).Err()
+	})
+}
+
+// InvalidateTags deletes every key stored with one of tags. Tags that cannot
+// be invalidated are kept and retried before this instance reads Redis again,
+// so it never serves a key it should have evicted.
+func (r *RedisCache) InvalidateTags(ctx context.Context, tags ...string) {
+	r.pendingMu.Lock()
+	for _, tag := range tags {
+		r.pending[tag] = true
+	}
+	r.pendingMu.Unlock()
+
+	// the mutation is done, evict even when its request was cancelled
+	logError("cache invalidate", r.flushPending(context.WithoutCancel(ctx)))
+}
+
+func (r *RedisCache) flushPending(ctx context.Context) error {
+	r.pendingMu.Lock()
+	defer r.pendingMu.Unlock()
+
+	if len(r.pending) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, 2*len(r.pending)+1)
+	keys = append(keys, GENERATION_KEY)
+	for tag := range r.pending {
+		keys = append(keys, TAG_PREFIX+tag, GENERATION_PREFIX+tag)
+	}
+
+	err := r.breaker.Do(func() error {
+		return invalidateScript.Run(ctx, r.client, keys, GENERATION_TTL.Milliseconds()).Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	clear(r.pending)
+	return nil
+}
+
+func (r *RedisCache) get(ctx context.Context, key string) (*entry, error) {
+	err := r.flushPending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	err = r.breaker.Do(func() (err error) {
+		content, err = r.client.Get(ctx, key).Bytes()
+		return
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	cached := new(entry)
+	err = json.Unmarshal(content, cached)
+	if err != nil {
+		return nil, err
+	}
+
+	return cached, nil
+}
+
+// set keeps the entry in Redis for ttl plus the stale window, unless one of
+// tags was invalidated after generation.
+func (r *RedisCache) set(ctx context.Context, key string, content []byte, ttl time.Duration, tags []string,
+	generation int64) error {
+
+	cached, err := json.Marshal(&entry{
+		Value:      content,
+		FreshUntil: time.Now().Add(ttl).UnixMilli(),
+		Tags:       tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, 2*len(tags)+1)
+	keys = append(keys, key)
+	for _, tag := range tags {
+		keys = append(keys, TAG_PREFIX+tag, GENERATION_PREFIX+tag)
+	}
+
+	return r.breaker.Do(func() error {
+		return setScript.Run(ctx, r.client, keys, cached, (ttl + r.options.StaleTTL).Milliseconds(), generation).Err()
+	})
+}
+
+// generation returns the current GENERATION_KEY.
+func (r *RedisCache) generation(ctx context.Context) (generation int64, err error) {
+	err = r.breaker.Do(func() (err error) {
+		generation, err = r.client.Get(ctx, GENERATION_KEY).Int64()
+		return
+	})
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return generation, err
+}
+
+// load calls the loader and caches its value unless a tag of it was
+// invalidated meanwhile, a failed write is only logged.
+func (r *RedisCache) load(ctx context.Context, key string, ttl time.Duration, load Loader) (*entry, error) {
+	generation, generationErr := r.generation(ctx)
+
+	value, tags, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// without the generation an invalidation could not be told apart, skip the write
+	if generationErr == nil {
+		logError("cache set "+key, r.set(ctx, key, content, ttl, tags, generation))
+	} else {
+		logError("cache generation", generationErr)
+	}
+
+	return &entry{Value: content, Tags: tags}, nil
+}
+
+// loadLocked loads key while holding its Redis lock. When another instance
+// holds it, the value it stores is awaited for up to LockWait before loading
+// without the lock. When Redis fails the lock is skipped.
+func (r *RedisCache) loadLocked(ctx context.Context, key string, ttl time.Duration, load Loader) (*entry, error) {
+	token, acquired, err := r.lock(ctx, key)
+	if err != nil {
+		return r.load(ctx, key, ttl, load)
+	}
+
+	if acquired {
+		defer r.unlock(ctx, key, token)
+
+		// another instance may have stored it between our miss and the lock
+		cached, err := r.get(ctx, key)
+		if err == nil && cached != nil && cached.fresh() {
+			return cached, nil
+		}
+
+		return r.load(ctx, key, ttl, load)
+	}
+
+	deadline := time.Now().Add(r.options.LockWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(LOCK_POLL_INTERVAL)
+
+		cached, err := r.get(ctx, key)
+		if err != nil {
+			break
+		}
+
+		if cached != nil {
+			return cached, nil
+		}
+	}
+
+	return r.load(ctx, key, ttl, load)
+}
+
+// revalidate refreshes key in the background unless this instance is already
+// refreshing it or another one holds its lock.
+func (r *RedisCache) revalidate(key string, ttl time.Duration, load Loader) {
+	if _, running := r.refreshing.LoadOrStore(key, true); running {
+		return
+	}
+
+	r.background(func(ctx context.Context) {
+		defer r.refreshing.Delete(key)
+
+		token, acquired, err := r.lock(ctx, key)
+		if err != nil || !acquired {
+			return
+		}
+
+		defer r.unlock(ctx, key, token)
+
+		_, err = r.load(ctx, key, ttl, load)
+		if err != nil {
+			tool.PrintLog("cache refresh "+key, err)
+		}
+	})
+}
+
+func (r *RedisCache) lock(ctx context.Context, key string) (token string, acquired bool, err error) {
+	random := make([]byte, 16)
+	_, err = rand.Read(random)
+	if err != nil {
+		return "", false, err
+	}
+
+	token = hex.EncodeToString(random)
+	err = r.breaker.Do(func() (err error) {
+		acquired, err = r.client.SetNX(ctx, LOCK_PREFIX+key, token, r.options.LockTTL).Result()
+		return
+	})
+
+	return token, acquired, err
+}
+
+func (r *RedisCache) unlock(ctx context.Context, key, token string) {
+	// release even when the request that took the lock was cancelled
+	err := r.breaker.Do(func() error {
+		return unlockScript.Run(context.WithoutCancel(ctx), r.client, []string{LOCK_PREFIX + key}, token).Err()
+	})
+	logError("cache unlock "+key, err)
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testOptions() Options {
+	return Options{
+		StaleTTL:         time.Minute,
+		LockTTL:          time.Second,
+		LockWait:         time.Second,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+// mutatingLoader returns stale and invalidates tag while it runs, as a
+// mutation committing between the read of the loader and its cache write.
+func mutatingLoader(cache Cache, tag, stale string) Loader {
+	return func(ctx context.Context) (any, []string, error) {
+		cache.InvalidateTags(ctx, tag)
+		return stale, []string{tag}, nil
+	}
+}
+
+func TestRedisCacheSkipsLoadsOlderThanInvalidation(t *testing.T) {
+	ctx := context.Background()
+	redisCache := NewRedisCache(newTestRedis(t), testOptions(), nil)
+
+	var value string
+	_, err := redisCache.Fetch(ctx, "article:1", &value, time.Minute, mutatingLoader(redisCache, "article:1", "old"))
+	if err != nil || value != "old" {
+		t.Fatalf("Fetch = %q, %v", value, err)
+	}
+
+	if hit, _ := redisCache.Get(ctx, "article:1", &value); hit {
+		t.Fatal("a load older than the invalidation of its tag was stored")
+	}
+
+	_, err = redisCache.Fetch(ctx, "article:1", &value, time.Minute, func(ctx context.Context) (any, []string, error) {
+		return "new", []string{"article:1"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hit, _ := redisCache.Get(ctx, "article:1", &value); !hit || value != "new" {
+		t.Fatalf("Get after a clean load = %q, %v", value, hit)
+	}
+}
+
+func TestRedisCacheInvalidatesTags(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+	redisCache := NewRedisCache(client, testOptions(), nil)
+
+	tagged := map[string][]string{
+		"article:1":   {"article:1", "user:1"},
+		"article:2":   {"article:2", "user:1", "category:1"},
+		"article:3":   {"article:3", "category:1"},
+		"articleList": {TAG_ARTICLES},
+	}
+	for key, tags := range tagged {
+		if err := redisCache.Set(ctx, key, key, time.Minute, tags...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	redisCache.InvalidateTags(ctx, "user:1", "category:1")
+
+	var value string
+	for key, want := range map[string]bool{"article:1": false, "article:2": false, "article:3": false, "articleList": true} {
+		if hit, _ := redisCache.Get(ctx, key, &value); hit != want {
+			t.Fatalf("%s cached = %v, want %v", key, hit, want)
+		}
+	}
+
+	if exists, _ := client.Exists(ctx, TAG_PREFIX+"user:1", TAG_PREFIX+"category:1").Result(); exists != 0 {
+		t.Fatal("invalidated tag sets kept")
+	}
+}
+
+func TestRedisCacheRefreshesStaleOnce(t *testing.T) {
+	ctx := context.Background()
+
+	var refreshes sync.WaitGroup
+	background := func(fn func(ctx context.Context)) {
+		refreshes.Add(1)
+		go func() {
+			defer refreshes.Done()
+			fn(context.Background())
+		}()
+	}
+
+	client := newTestRedis(t)
+	redisCache := NewRedisCache(client, testOptions(), background)
+	if err := redisCache.Set(ctx, "article:1", "old", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (any, []string, error) {
+		loads.Add(1)
+		<-release
+		return "new", nil, nil
+	}
+
+	// a second instance on the same Redis is kept out by the lock
+	other := NewRedisCache(client, testOptions(), background)
+	for _, instance := range []*RedisCache{redisCache, redisCache, other, other} {
+		var value string
+		hit, err := instance.Fetch(ctx, "article:1", &value, time.Minute, load)
+		if err != nil || !hit || value != "old" {
+			t.Fatalf("Fetch of a stale key = %q, %v, %v", value, hit, err)
+		}
+	}
+
+	close(release)
+	refreshes.Wait()
+
+	if loads.Load() != 1 {
+		t.Fatalf("loads = %d, want 1", loads.Load())
+	}
+
+	var value string
+	if hit, _ := redisCache.Get(ctx, "article:1", &value); !hit || value != "new" {
+		t.Fatalf("Get after the refresh = %q, %v", value, hit)
+	}
+}
+
+func TestRedisCacheFallsBackToLoader(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	options := testOptions()
+	redisCache := NewRedisCache(client, options, nil)
+	server.Close()
+
+	for range options.BreakerThreshold + 1 {
+		var value string
+		hit, err := redisCache.Fetch(ctx, "article:1", &value, time.Minute, func(ctx context.Context) (any, []string, error) {
+			return "loaded", []string{"article:1"}, nil
+		})
+		if err != nil || hit || value != "loaded" {
+			t.Fatalf("Fetch without Redis = %q, %v, %v", value, hit, err)
+		}
+	}
+
+	if redisCache.breaker.Allow() {
+		t.Fatal("breaker closed after consecutive failures")
+	}
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// INVALIDATION_CHANNEL carries the keys and tags evicted by one instance to
+// the local tier of the others.
+const INVALIDATION_CHANNEL = "cache:invalidate"
+
+const RESUBSCRIBE_DELAY = 1 * time.Second
+
+// TieredCache keeps a small in-process LRU in front of RedisCache, so hot keys
+// skip the network hop. Evictions are published to every instance; a local
+// entry lives at most localTTL, which bounds staleness when a message is lost.
+type TieredCache struct {
+	local    *MemoryCache
+	remote   *RedisCache
+	localTTL time.Duration
+
+	// origin identifies this instance so it ignores its own messages.
+	origin string
+}
+
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	// Purge empties the whole local tier, e.g. after the database was flushed.
+	Purge bool `json:"purge,omitempty"`
+}
+
+// PublishInvalidation evicts keys and tags from the local tier of every
+// running TieredCache, or all of it with purge, for processes changing Redis
+// without one, like the cache flush command. The message has no origin, so
+// every instance applies it.
+func PublishInvalidation(ctx context.Context, client *redis.Client, keys []string, tags []string, purge bool) error {
+	payload, err := json.Marshal(&invalidation{Keys: keys, Tags: tags, Purge: purge})
+	if err != nil {
+		return err
+	}
+
+	return client.Publish(ctx, INVALIDATION_CHANNEL, payload).Err()
+}
+
+// NewTieredCache returns a cache holding up to localSize entries locally for
+// at most localTTL. It subscribes to INVALIDATION_CHANNEL through background
+// until the background context is cancelled.
+func NewTieredCache(client *redis.Client, options Options, localSize int, localTTL time.Duration,
+	background func(fn func(ctx context.Context))) *TieredCache {
+
+	random := make([]byte, 8)
+	_, _ = rand.Read(random)
+
+	background = runner(background)
+	tiered := &TieredCache{
+		// no stale window, the remote tier serves stale values
+		local:    NewMemoryCache(localSize, 0, background),
+		remote:   NewRedisCache(client, options, background),
+		localTTL: localTTL,
+		origin:   hex.EncodeToString(random),
+	}
+
+	background(tiered.subscribe)
+
+	return tiered
+}
+
+func (t *TieredCache) Get(ctx context.Context, key string, dest any) (hit bool, err error) {
+	hit, _ = t.local.Get(ctx, key, dest)
+	if hit {
+		return true, nil
+	}
+
+	// an eviction received while reading Redis must not be undone locally
+	generation := t.local.startLoad()
+	defer t.local.endLoad()
+
+	cached, err := t.remote.get(ctx, key)
+	if err != nil || cached == nil {
+		return false, err
+	}
+
+	t.local.set(key, cached.Value, t.localTTLFor(cached), cached.Tags, generation)
+
+	return true, json.Unmarshal(cached.Value, dest)
+}
+
+func (t *TieredCache) Set(ctx context.Context, key string, value any, ttl time.Duration, tags ...string) error {
+	err := t.remote.Set(ctx, key, value, ttl, tags...)
+	if err != nil {
+		return err
+	}
+
+	// the other instances read the new value from Redis on their next miss
+	_ = t.local.Delete(ctx, key)
+	t.publish(ctx, &invalidation{Keys: []string{key}})
+
+	return nil
+}
+
+// Fetch looks up the local tier, then RedisCache.Fetch, and keeps the value
+// locally. It reports a hit when either tier had the key.
+func (t *TieredCache) Fetch(ctx context.Context, key string, dest any, ttl time.Duration, load Loader) (
+	hit bool, err error) {
+
+	remoteHit := false
+	hit, err = t.local.Fetch(ctx, key, dest, min(ttl, t.localTTL), func(ctx context.Context) (any, []string, error) {
+		cached, hit, err := t.remote.fetch(ctx, key, ttl, load)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		remoteHit = hit
+		return cached.Value, cached.Tags, nil
+	})
+
+	return hit || remoteHit, err
+}
+
+func (t *TieredCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_ = t.local.Delete(ctx, keys...)
+	err := t.remote.Delete(ctx, keys...)
+	t.publish(ctx, &invalidation{Keys: keys})
+
+	return err
+}
+
+func (t *TieredCache) InvalidateTags(ctx context.Context, tags ...string) {
+	t.local.InvalidateTags(ctx, tags...)
+	t.remote.InvalidateTags(ctx, tags...)
+	t.publish(ctx, &invalidation{Tags: tags})
+}
+
+// localTTLFor keeps a remote entry locally no longer than it stays fresh.
+func (t *TieredCache) localTTLFor(cached *entry) time.Duration {
+	return max(0, min(t.localTTL, time.Until(time.UnixMilli(cached.FreshUntil))))
+}
+
+func (t *TieredCache) publish(ctx context.Context, message *invalidation) {
+	message.Origin = t.origin
+	payload, err := json.Marshal(message)
+	if err != nil {
+		logError("cache publish", err)
+		return
+	}
+
+	err = t.remote.breaker.Do(func() error {
+		return t.remote.client.Publish(context.WithoutCancel(ctx), INVALIDATION_CHANNEL, payload).Err()
+	})
+	logError("cache publish", err)
+}
+
+// subscribe applies the evictions of other instances to the local tier. The
+// local tier is purged on every (re)subscription since messages sent while
+// disconnected are lost.
+func (t *TieredCache) subscribe(ctx context.Context) {
+	pubsub := t.remote.client.Subscribe(ctx, INVALIDATION_CHANNEL)
+	defer func() {
+		_ = pubsub.Close()
+	}()
+
+	// Receive blocks on the connection whatever ctx, closing it unblocks Receive
+	stop := context.AfterFunc(ctx, func() {
+		_ = pubsub.Close()
+	})
+	defer stop()
+
+	// log once per outage, Receive fails every RESUBSCRIBE_DELAY until Redis is back
+	subscribed := true
+	for {
+		received, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if subscribed {
+				logError("cache subscribe", err)
+				subscribed = false
+			}
+
+			t.local.Purge()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(RESUBSCRIBE_DELAY):
+			}
+
+			continue
+		}
+
+		switch message := received.(type) {
+		case *redis.Subscription:
+			subscribed = true
+			t.local.Purge()
+		case *redis.Message:
+			evicted := new(invalidation)
+			if json.Unmarshal([]byte(message.Payload), evicted) != nil || evicted.Origin == t.origin {
+				continue
+			}
+
+			if evicted.Purge {
+				t.local.Purge()
+				continue
+			}
+
+			_ = t.local.Delete(ctx, evicted.Keys...)
+			t.local.InvalidateTags(ctx, evicted.Tags...)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestTiered returns an instance subscribed to INVALIDATION_CHANNEL of
+// server until the test ends.
+func newTestTiered(t *testing.T, server *miniredis.Miniredis) *TieredCache {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	started := false
+	background := func(fn func(ctx context.Context)) {
+		if started {
+			go fn(ctx)
+			return
+		}
+
+		// the first one is the subscription
+		started = true
+		go func() {
+			defer close(done)
+			fn(ctx)
+		}()
+	}
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = client.Close()
+	})
+
+	return NewTieredCache(client, testOptions(), 10, time.Minute, background)
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for " + what)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTieredCacheEvictsOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	first, second := newTestTiered(t, server), newTestTiered(t, server)
+
+	waitFor(t, "both subscriptions", func() bool {
+		return server.PubSubNumSub(INVALIDATION_CHANNEL)[INVALIDATION_CHANNEL] == 2
+	})
+
+	// the local tier is purged when the subscription is confirmed
+	time.Sleep(50 * time.Millisecond)
+
+	for _, key := range []string{"article:1", "article:2"} {
+		if err := first.Set(ctx, key, key, time.Minute, key, "user:1"); err != nil {
+			t.Fatal(err)
+		}
+
+		var value string
+		if hit, err := second.Get(ctx, key, &value); !hit || err != nil || value != key {
+			t.Fatalf("second Get %s = %q, %v, %v", key, value, hit, err)
+		}
+
+		if second.local.get(key) == nil {
+			t.Fatalf("%s not kept in the local tier", key)
+		}
+	}
+
+	first.InvalidateTags(ctx, "user:1")
+
+	waitFor(t, "the local eviction on the second instance", func() bool {
+		return second.local.get("article:1") == nil && second.local.get("article:2") == nil
+	})
+
+	// a key written by one instance is re-read from Redis by the other
+	if err := second.Set(ctx, "article:3", "old", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if hit, _ := first.Get(ctx, "article:3", &value); !hit || value != "old" {
+		t.Fatalf("first Get = %q, %v", value, hit)
+	}
+
+	if err := second.Set(ctx, "article:3", "new", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the local eviction on the first instance", func() bool {
+		return first.local.get("article:3") == nil
+	})
+
+	if hit, _ := first.Get(ctx, "article:3", &value); !hit || value != "new" {
+		t.Fatalf("first Get after the update = %q, %v", value, hit)
+	}
+}
+
+func TestPublishInvalidationEvictsLocalTiers(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	tiered := newTestTiered(t, server)
+
+	waitFor(t, "the subscription", func() bool {
+		return server.PubSubNumSub(INVALIDATION_CHANNEL)[INVALIDATION_CHANNEL] == 1
+	})
+
+	// the local tier is purged when the subscription is confirmed
+	time.Sleep(50 * time.Millisecond)
+
+	for key, tag := range map[string]string{"article:1": "article:1", "articleList": TAG_ARTICLES, "other": "other"} {
+		if err := tiered.local.Set(ctx, key, key, time.Minute, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// what the cache flush command publishes without a TieredCache of its own
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if err := PublishInvalidation(ctx, client, []string{"article:1"}, []string{TAG_ARTICLES}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the flushed keys", func() bool {
+		return tiered.local.get("article:1") == nil && tiered.local.get("articleList") == nil
+	})
+
+	if tiered.local.get("other") == nil {
+		t.Fatal("a key that was not flushed was evicted")
+	}
+
+	if err := PublishInvalidation(ctx, client, nil, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the purge", func() bool {
+		return tiered.local.get("other") == nil
+	})
+}
@@ -0,0 +1,17 @@
+package config
+
+const (
+	// SESSION_STORE_DOWN_REJECT answers 503 to authenticated requests while
+	// Redis is unreachable, logged out tokens stay rejected.
+	SESSION_STORE_DOWN_REJECT = "reject"
+
+	// SESSION_STORE_DOWN_ALLOW trusts any validly signed, unexpired token while
+	// Redis is unreachable, so a token logged out before the outage is accepted
+	// until it expires.
+	SESSION_STORE_DOWN_ALLOW = "allow"
+)
+
+// AuthConfig decides how AuthMiddleware behaves when the session store fails.
+type AuthConfig struct {
+	SessionStoreDown string `yaml:"session_store_down" env:"AUTH_SESSION_STORE_DOWN" default:"reject"`
+}
@@ -0,0 +1,31 @@
+package config
+
+import "time"
+
+const (
+	CACHE_BACKEND_REDIS  = "redis"
+	CACHE_BACKEND_MEMORY = "memory"
+	CACHE_BACKEND_TIERED = "tiered"
+)
+
+// CacheConfig tunes the article cache. An expired entry is still served for
+// StaleTTL while one worker refreshes it; LockTTL bounds how long a worker
+// holds the refresh lock and LockWait how long other instances wait for it
+// on a miss before loading the value themselves. After BreakerThreshold
+// consecutive Redis failures the cache is bypassed for BreakerCooldown.
+//
+// Backend is redis, memory (an in-process LRU, for a single instance only) or
+// tiered (an in-process LRU of LocalSize entries kept at most LocalTTL in
+// front of Redis, evicted across instances through pub/sub).
+type CacheConfig struct {
+	Backend   string        `yaml:"backend" env:"CACHE_BACKEND" default:"redis"`
+	LocalSize int           `yaml:"local_size" env:"CACHE_LOCAL_SIZE" default:"10000"`
+	LocalTTL  time.Duration `yaml:"local_ttl" env:"CACHE_LOCAL_TTL" default:"30s"`
+
+	StaleTTL time.Duration `yaml:"stale_ttl" env:"CACHE_STALE_TTL" default:"5m"`
+	LockTTL  time.Duration `yaml:"lock_ttl" env:"CACHE_LOCK_TTL" default:"10s"`
+	LockWait time.Duration `yaml:"lock_wait" env:"CACHE_LOCK_WAIT" default:"2s"`
+
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"CACHE_BREAKER_THRESHOLD" default:"5"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"CACHE_BREAKER_COOLDOWN" default:"30s"`
+}
@@ -0,0 +1,396 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_ENV_FILE  = ".env"
+	DEFAULT_YAML_FILE = "config.yaml"
+	REDACTED          = "******"
+)
+
+// AppConfig is the whole application configuration.
+//
+// Values are resolved with the following precedence, lowest first:
+// `default` tags, the YAML file, the .env file, then the process environment.
+// The .env and YAML files are both optional.
+type AppConfig struct {
+	GinMode       string `yaml:"gin_mode" env:"GIN_MODE" default:"debug"`
+	ClientFile    string `yaml:"client_file" env:"APP_CLIENT_FILE"`
+	JwtSigningKey string `yaml:"jwt_signing_key" env:"JWT_SIGNING_KEY" required:"true" secret:"true"`
+	// ProblemJSON renders every error as application/problem+json instead of the
+	// standard envelope; clients can also ask for it per request with Accept.
+	ProblemJSON bool `yaml:"problem_json" env:"APP_PROBLEM_JSON" default:"false"`
+
+	Server    ServerConfig     `yaml:"server"`
+	Postgres  PostgresDBConfig `yaml:"postgres"`
+	Redis     RedisDBConfig    `yaml:"redis"`
+	Tracing   TracingConfig    `yaml:"tracing"`
+	RateLimit RateLimitConfig  `yaml:"rate_limit"`
+	CORS      CORSConfig       `yaml:"cors"`
+	Security  SecurityConfig   `yaml:"security"`
+	I18n      I18nConfig       `yaml:"i18n"`
+
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Cache       CacheConfig       `yaml:"cache"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Media       MediaConfig       `yaml:"media"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none"`
+}
+
+// I18nConfig selects the message catalogs. With an empty Dir the catalogs
+// embedded in the binary are used, otherwise every <locale>.yaml in Dir.
+type I18nConfig struct {
+	Dir           string `yaml:"dir" env:"APP_I18N_DIR"`
+	DefaultLocale string `yaml:"default_locale" env:"APP_DEFAULT_LOCALE" default:"en"`
+}
+
+// ValidationError lists every missing or invalid configuration key.
+type ValidationError struct {
+	Problems []string
+}
+
+func (v *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(v.Problems, "\n  - ")
+}
+
+// Load reads the configuration. An empty yamlPath falls back to APP_CONFIG_FILE
+// and then to config.yaml; only an explicitly requested file must exist.
+func Load(yamlPath string) (appConfig *AppConfig, err error) {
+	err = godotenv.Load(DEFAULT_ENV_FILE)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load %s: %w", DEFAULT_ENV_FILE, err)
+	}
+
+	appConfig = new(AppConfig)
+	validation := new(ValidationError)
+
+	applyDefaults(reflect.ValueOf(appConfig).Elem(), validation)
+
+	err = appConfig.loadYAML(yamlPath, validation)
+	if err != nil {
+		return nil, err
+	}
+
+	applyEnv(reflect.ValueOf(appConfig).Elem(), validation)
+	checkRequired(reflect.ValueOf(appConfig).Elem(), validation)
+	appConfig.validate(validation)
+
+	if len(validation.Problems) > 0 {
+		return nil, validation
+	}
+
+	return appConfig, nil
+}
+
+func (a *AppConfig) loadYAML(path string, validation *ValidationError) error {
+	explicit := true
+	if path == "" {
+		path = os.Getenv("APP_CONFIG_FILE")
+	}
+
+	if path == "" {
+		path = DEFAULT_YAML_FILE
+		explicit = false
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("load %s: %w", path, err)
+	}
+
+	err = yaml.Unmarshal(content, a)
+	if err != nil {
+		var typeError *yaml.TypeError
+		if errors.As(err, &typeError) {
+			for _, problem := range typeError.Errors {
+				validation.Problems = append(validation.Problems, path+": "+problem)
+			}
+
+			return nil
+		}
+
+		return fmt.Errorf("load %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (a *AppConfig) validate(validation *ValidationError) {
+	switch a.GinMode {
+	case "debug", "release", "test":
+	default:
+		validation.Problems = append(validation.Problems,
+			fmt.Sprintf("GIN_MODE: must be one of debug, release, test, got %q", a.GinMode))
+	}
+
+	switch a.Tracing.Exporter {
+	case "none", "otlp":
+	default:
+		validation.Problems = append(validation.Problems,
+			fmt.Sprintf("OTEL_TRACES_EXPORTER: must be one of none, otlp, got %q", a.Tracing.Exporter))
+	}
+
+	if a.CORS.AllowCredentials && a.CORS.AllowsAnyOrigin() {
+		validation.Problems = append(validation.Problems,
+			"CORS_ALLOW_CREDENTIALS: cannot be true while CORS_ALLOWED_ORIGINS contains \"*\"")
+	}
+
+	if a.Security.MaxBodySize <= 0 || a.Security.MaxArticleBodySize <= 0 {
+		validation.Problems = append(validation.Problems,
+			"SECURITY_MAX_BODY_SIZE, SECURITY_MAX_ARTICLE_BODY_SIZE: must be positive")
+	}
+
+	a.Server.validate(validation)
+	a.Postgres.validate(validation)
+	a.Redis.validate(validation)
+
+	if a.Idempotency.Window <= 0 || a.Idempotency.LockTTL <= 0 {
+		validation.Problems = append(validation.Problems,
+			"IDEMPOTENCY_WINDOW, IDEMPOTENCY_LOCK_TTL: must be positive")
+	}
+
+	if a.Cache.StaleTTL < 0 || a.Cache.LockTTL <= 0 || a.Cache.LockWait <= 0 {
+		validation.Problems = append(validation.Problems,
+			"CACHE_STALE_TTL: must not be negative, CACHE_LOCK_TTL, CACHE_LOCK_WAIT: must be positive")
+	}
+
+	switch a.Cache.Backend {
+	case CACHE_BACKEND_REDIS, CACHE_BACKEND_MEMORY, CACHE_BACKEND_TIERED:
+	default:
+		validation.Problems = append(validation.Problems,
+			fmt.Sprintf("CACHE_BACKEND: must be one of redis, memory, tiered, got %q", a.Cache.Backend))
+	}
+
+	if a.Cache.LocalSize <= 0 || a.Cache.LocalTTL <= 0 {
+		validation.Problems = append(validation.Problems,
+			"CACHE_LOCAL_SIZE, CACHE_LOCAL_TTL: must be positive")
+	}
+
+	if a.Cache.BreakerThreshold <= 0 || a.Cache.BreakerCooldown <= 0 {
+		validation.Problems = append(validation.Problems,
+			"CACHE_BREAKER_THRESHOLD, CACHE_BREAKER_COOLDOWN: must be positive")
+	}
+
+	switch a.Auth.SessionStoreDown {
+	case SESSION_STORE_DOWN_REJECT, SESSION_STORE_DOWN_ALLOW:
+	default:
+		validation.Problems = append(validation.Problems,
+			fmt.Sprintf("AUTH_SESSION_STORE_DOWN: must be one of reject, allow, got %q", a.Auth.SessionStoreDown))
+	}
+
+	switch a.Media.Backend {
+	case MEDIA_BACKEND_LOCAL:
+	case MEDIA_BACKEND_S3:
+		if a.Media.S3Endpoint == "" || a.Media.S3Bucket == "" || a.Media.S3AccessKey == "" || a.Media.S3SecretKey == "" {
+			validation.Problems = append(validation.Problems,
+				"MEDIA_S3_ENDPOINT, MEDIA_S3_BUCKET, MEDIA_S3_ACCESS_KEY, MEDIA_S3_SECRET_KEY: are required by the s3 backend")
+		}
+
+		if a.Media.S3Timeout <= 0 {
+			validation.Problems = append(validation.Problems, "MEDIA_S3_TIMEOUT: must be positive")
+		}
+	default:
+		validation.Problems = append(validation.Problems,
+			fmt.Sprintf("MEDIA_BACKEND: must be one of local, s3, got %q", a.Media.Backend))
+	}
+
+	if a.Media.MaxSize <= 0 || len(a.Media.AllowedTypes) == 0 {
+		validation.Problems = append(validation.Problems,
+			"MEDIA_MAX_SIZE: must be positive, MEDIA_ALLOWED_TYPES: must not be empty")
+	}
+
+	for _, rule := range a.RateLimit.rules() {
+		switch rule.Identity {
+		case RATE_LIMIT_IDENTITY_IP, RATE_LIMIT_IDENTITY_USER, RATE_LIMIT_IDENTITY_TOKEN:
+		default:
+			validation.Problems = append(validation.Problems,
+				fmt.Sprintf("rate limit %s identity: must be one of ip, user, token, got %q", rule.Name, rule.Identity))
+		}
+
+		if rule.Limit > 0 && rule.Window <= 0 {
+			validation.Problems = append(validation.Problems,
+				fmt.Sprintf("rate limit %s window: must be positive", rule.Name))
+		}
+	}
+}
+
+func (a *AppConfig) String() string {
+	return Redacted(*a)
+}
+
+// Redacted prints a configuration struct as YAML with every `secret` field masked.
+func Redacted(v any) string {
+	content, err := yaml.Marshal(redact(reflect.ValueOf(v)))
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(content)
+}
+
+func applyDefaults(v reflect.Value, validation *ValidationError) {
+	eachField(v, func(field reflect.Value, structField reflect.StructField) {
+		value, ok := structField.Tag.Lookup("default")
+		if !ok {
+			return
+		}
+
+		err := setField(field, value)
+		if err != nil {
+			validation.Problems = append(validation.Problems,
+				fmt.Sprintf("%s: invalid default %q: %v", keyOf(structField), value, err))
+		}
+	})
+}
+
+func applyEnv(v reflect.Value, validation *ValidationError) {
+	eachField(v, func(field reflect.Value, structField reflect.StructField) {
+		key := structField.Tag.Get("env")
+		if key == "" {
+			return
+		}
+
+		value, ok := os.LookupEnv(key)
+		if !ok || value == "" {
+			return
+		}
+
+		err := setField(field, value)
+		if err != nil {
+			validation.Problems = append(validation.Problems,
+				fmt.Sprintf("%s: invalid value %q: %v", key, value, err))
+		}
+	})
+}
+
+func checkRequired(v reflect.Value, validation *ValidationError) {
+	eachField(v, func(field reflect.Value, structField reflect.StructField) {
+		if structField.Tag.Get("required") == "true" && field.IsZero() {
+			validation.Problems = append(validation.Problems, keyOf(structField)+": is required")
+		}
+	})
+}
+
+// eachField calls fn for every leaf field, descending into nested structs.
+func eachField(v reflect.Value, fn func(field reflect.Value, structField reflect.StructField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			eachField(field, fn)
+			continue
+		}
+
+		fn(field, structField)
+	}
+}
+
+func keyOf(structField reflect.StructField) string {
+	if key := structField.Tag.Get("env"); key != "" {
+		return key
+	}
+
+	return strings.Split(structField.Tag.Get("yaml"), ",")[0]
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(i)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+
+		items := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+func redact(v reflect.Value) map[string]any {
+	result := make(map[string]any)
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		key := strings.Split(structField.Tag.Get("yaml"), ",")[0]
+		if key == "" {
+			key = structField.Name
+		}
+
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			result[key] = redact(field)
+		case structField.Tag.Get("secret") == "true" && !field.IsZero():
+			result[key] = REDACTED
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			result[key] = time.Duration(field.Int()).String()
+		default:
+			result[key] = field.Interface()
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// MAX_CONNECT_BACKOFF caps the wait between two startup connection attempts.
+const MAX_CONNECT_BACKOFF = 30 * time.Second
+
+// retryConnect calls ping up to attempts times with CONNECT_TIMEOUT each,
+// doubling the wait from backoff up to MAX_CONNECT_BACKOFF between attempts,
+// so the service survives starting before its databases.
+func retryConnect(name string, attempts int, backoff time.Duration, ping func(ctx context.Context) error) (
+	err error) {
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), CONNECT_TIMEOUT)
+		err = ping(ctx)
+		cancel()
+
+		if err == nil || attempt >= attempts {
+			return err
+		}
+
+		log.Printf("%s not ready (attempt %d of %d), retrying in %s: %v", name, attempt, attempts, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, MAX_CONNECT_BACKOFF)
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryConnect(t *testing.T) {
+	unavailable := errors.New("connection refused")
+
+	for name, test := range map[string]struct {
+		attempts  int
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		"first attempt":      {attempts: 3, failures: 0, wantCalls: 1},
+		"after failures":     {attempts: 3, failures: 2, wantCalls: 3},
+		"out of attempts":    {attempts: 3, failures: 5, wantCalls: 3, wantErr: true},
+		"single attempt":     {attempts: 1, failures: 1, wantCalls: 1, wantErr: true},
+		"attempts below one": {attempts: 0, failures: 1, wantCalls: 1, wantErr: true},
+	} {
+		calls := 0
+		err := retryConnect("test", test.attempts, time.Millisecond, func(ctx context.Context) error {
+			calls++
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("%s: ping without a deadline", name)
+			}
+
+			if calls <= test.failures {
+				return unavailable
+			}
+
+			return nil
+		})
+
+		if calls != test.wantCalls {
+			t.Errorf("%s: %d calls, want %d", name, calls, test.wantCalls)
+		}
+
+		if test.wantErr != errors.Is(err, unavailable) || (!test.wantErr && err != nil) {
+			t.Errorf("%s: retryConnect = %v", name, err)
+		}
+	}
+}
+
+func TestRetryConnectBacksOff(t *testing.T) {
+	backoff := 10 * time.Millisecond
+	start := time.Now()
+
+	_ = retryConnect("test", 3, backoff, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	// waits backoff, then twice as long
+	if elapsed := time.Since(start); elapsed < 3*backoff {
+		t.Fatalf("retried after %s, want at least %s", elapsed, 3*backoff)
+	}
+}
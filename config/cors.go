@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// CORSConfig is the cross-origin policy. AllowedOrigins entries are exact
+// origins ("https://admin.example.com"), wildcard subdomains
+// ("https://*.example.com") or "*" for any origin without credentials.
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" default:"*"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE,OPTIONS"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" default:"Content-Type,Content-Length,Accept-Encoding,X-CSRF-Token,Authorization,Accept,Accept-Language,Origin,Cache-Control,X-Requested-With,If-Match,If-None-Match,Idempotency-Key"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS" default:"X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset,Retry-After,ETag,Idempotent-Replayed"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" default:"false"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" default:"10m"`
+}
+
+func (c *CORSConfig) AllowsAnyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *CORSConfig) IsOriginAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		prefix, suffix, found := strings.Cut(allowed, "*")
+		if !found {
+			continue
+		}
+
+		// "https://*.example.com" matches "https://admin.example.com" but not
+		// "https://.example.com" nor "https://evil.com/.example.com"
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			len(origin) > len(prefix)+len(suffix) {
+
+			subdomain := origin[len(prefix) : len(origin)-len(suffix)]
+			if !strings.ContainsAny(subdomain, "/:@") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
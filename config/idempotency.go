@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// IdempotencyConfig controls how long the first response to an Idempotency-Key
+// is kept for replay, and how long a request holding the key may run before
+// another attempt is allowed to take over.
+type IdempotencyConfig struct {
+	Window  time.Duration `yaml:"window" env:"IDEMPOTENCY_WINDOW" default:"24h"`
+	LockTTL time.Duration `yaml:"lock_ttl" env:"IDEMPOTENCY_LOCK_TTL" default:"1m"`
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	MEDIA_BACKEND_LOCAL = "local"
+	MEDIA_BACKEND_S3    = "s3"
+
+	// MEDIA_MULTIPART_OVERHEAD is allowed on top of MaxSize for the multipart
+	// boundaries and part headers.
+	MEDIA_MULTIPART_OVERHEAD = 64 << 10
+)
+
+// MediaConfig controls uploads. Backend is local (files under Dir) or s3 (a
+// bucket of an S3 compatible service such as AWS S3 or MinIO). Uploads are
+// limited to MaxSize bytes and to AllowedTypes, as sniffed from the content.
+//
+// PublicURL prefixes the storage key in media URLs. The default serves the
+// files through the API, a public bucket or CDN URL avoids that hop.
+type MediaConfig struct {
+	Backend      string   `yaml:"backend" env:"MEDIA_BACKEND" default:"local"`
+	Dir          string   `yaml:"dir" env:"MEDIA_DIR" default:"media"`
+	PublicURL    string   `yaml:"public_url" env:"MEDIA_PUBLIC_URL" default:"/api/v1/media/files"`
+	MaxSize      int64    `yaml:"max_size" env:"MEDIA_MAX_SIZE" default:"5242880"`
+	AllowedTypes []string `yaml:"allowed_types" env:"MEDIA_ALLOWED_TYPES" default:"image/jpeg,image/png,image/gif,image/webp"`
+
+	S3Endpoint  string `yaml:"s3_endpoint" env:"MEDIA_S3_ENDPOINT"`
+	S3Region    string `yaml:"s3_region" env:"MEDIA_S3_REGION" default:"us-east-1"`
+	S3Bucket    string `yaml:"s3_bucket" env:"MEDIA_S3_BUCKET"`
+	S3AccessKey string `yaml:"s3_access_key" env:"MEDIA_S3_ACCESS_KEY"`
+	S3SecretKey string `yaml:"s3_secret_key" env:"MEDIA_S3_SECRET_KEY" secret:"true"`
+	// S3Timeout bounds each request to the bucket, including streaming the
+	// body, so a stalled endpoint cannot hang uploads and downloads.
+	S3Timeout time.Duration `yaml:"s3_timeout" env:"MEDIA_S3_TIMEOUT" default:"30s"`
+}
+
+// Allows reports whether contentType may be uploaded.
+func (m *MediaConfig) Allows(contentType string) bool {
+	for _, allowed := range m.AllowedTypes {
+		if allowed == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// S3Client returns the HTTP client of the s3 backend.
+func (m *MediaConfig) S3Client() *http.Client {
+	return &http.Client{Timeout: m.S3Timeout}
+}
+
+// UploadLimit is the largest upload request body.
+func (m *MediaConfig) UploadLimit() int64 {
+	return m.MaxSize + MEDIA_MULTIPART_OVERHEAD
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestDSN(t *testing.T) {
+	base := PostgresDBConfig{Host: "db", Port: "5432", User: "goblog", Pass: "secret", Name: "goblog", SslMode: "disable"}
+
+	for name, test := range map[string]struct {
+		config func(db *PostgresDBConfig)
+		want   string
+	}{
+		"fields": {
+			func(db *PostgresDBConfig) {},
+			`host='db' port='5432' user='goblog' password='secret' dbname='goblog' sslmode='disable'`,
+		},
+		"password with a space": {
+			func(db *PostgresDBConfig) { db.Pass = "two words" },
+			`host='db' port='5432' user='goblog' password='two words' dbname='goblog' sslmode='disable'`,
+		},
+		"password with quotes and backslashes": {
+			func(db *PostgresDBConfig) { db.Pass = `it's a \ b` },
+			`host='db' port='5432' user='goblog' password='it\'s a \\ b' dbname='goblog' sslmode='disable'`,
+		},
+		"empty password": {
+			func(db *PostgresDBConfig) { db.Pass = "" },
+			`host='db' port='5432' user='goblog' password='' dbname='goblog' sslmode='disable'`,
+		},
+		"certificates": {
+			func(db *PostgresDBConfig) {
+				db.SslMode = "verify-full"
+				db.SslRootCert = "/certs/ca.pem"
+				db.SslCert = "/certs/client.pem"
+				db.SslKey = "/certs/client key.pem"
+			},
+			`host='db' port='5432' user='goblog' password='secret' dbname='goblog' sslmode='verify-full' ` +
+				`sslrootcert='/certs/ca.pem' sslcert='/certs/client.pem' sslkey='/certs/client key.pem'`,
+		},
+		"url wins": {
+			func(db *PostgresDBConfig) { db.URL = "postgres://goblog:secret@db:5432/goblog" },
+			"postgres://goblog:secret@db:5432/goblog",
+		},
+	} {
+		db := base
+		test.config(&db)
+
+		dsn := db.DSN()
+		if dsn != test.want {
+			t.Errorf("%s: DSN = %s, want %s", name, dsn, test.want)
+			continue
+		}
+
+		// the driver must parse it back, an unquoted space would split the password
+		if _, err := pq.NewConnector(dsn); err != nil {
+			t.Errorf("%s: pq rejects %s: %v", name, dsn, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import "time"
+
+const (
+	RATE_LIMIT_IDENTITY_IP    = "ip"
+	RATE_LIMIT_IDENTITY_USER  = "user"
+	RATE_LIMIT_IDENTITY_TOKEN = "token"
+)
+
+// RateLimitRule allows Limit requests per Window for each identity
+// (client IP, logged-in user id or access token). A Limit of 0 disables it.
+type RateLimitRule struct {
+	Name     string
+	Limit    int
+	Window   time.Duration
+	Identity string
+}
+
+type RateLimitConfig struct {
+	LoginLimit    int           `yaml:"login_limit" env:"RATE_LIMIT_LOGIN_LIMIT" default:"5"`
+	LoginWindow   time.Duration `yaml:"login_window" env:"RATE_LIMIT_LOGIN_WINDOW" default:"1m"`
+	LoginIdentity string        `yaml:"login_identity" env:"RATE_LIMIT_LOGIN_IDENTITY" default:"ip"`
+
+	PublicLimit    int           `yaml:"public_limit" env:"RATE_LIMIT_PUBLIC_LIMIT" default:"120"`
+	PublicWindow   time.Duration `yaml:"public_window" env:"RATE_LIMIT_PUBLIC_WINDOW" default:"1m"`
+	PublicIdentity string        `yaml:"public_identity" env:"RATE_LIMIT_PUBLIC_IDENTITY" default:"ip"`
+
+	AuthenticatedLimit    int           `yaml:"authenticated_limit" env:"RATE_LIMIT_AUTHENTICATED_LIMIT" default:"300"`
+	AuthenticatedWindow   time.Duration `yaml:"authenticated_window" env:"RATE_LIMIT_AUTHENTICATED_WINDOW" default:"1m"`
+	AuthenticatedIdentity string        `yaml:"authenticated_identity" env:"RATE_LIMIT_AUTHENTICATED_IDENTITY" default:"user"`
+}
+
+func (r *RateLimitConfig) Login() *RateLimitRule {
+	return &RateLimitRule{"login", r.LoginLimit, r.LoginWindow, r.LoginIdentity}
+}
+
+func (r *RateLimitConfig) Public() *RateLimitRule {
+	return &RateLimitRule{"public", r.PublicLimit, r.PublicWindow, r.PublicIdentity}
+}
+
+func (r *RateLimitConfig) Authenticated() *RateLimitRule {
+	return &RateLimitRule{"authenticated", r.AuthenticatedLimit, r.AuthenticatedWindow, r.AuthenticatedIdentity}
+}
+
+func (r *RateLimitConfig) rules() []*RateLimitRule {
+	return []*RateLimitRule{r.Login(), r.Public(), r.Authenticated()}
+}
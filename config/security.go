@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// SecurityConfig holds the response security headers and request body limits.
+// An empty header value (or a zero HSTS max age) leaves that header out.
+type SecurityConfig struct {
+	ContentSecurityPolicy string        `yaml:"content_security_policy" env:"SECURITY_CONTENT_SECURITY_POLICY" default:"default-src 'none'; frame-ancestors 'none'"`
+	ReferrerPolicy        string        `yaml:"referrer_policy" env:"SECURITY_REFERRER_POLICY" default:"strict-origin-when-cross-origin"`
+	FrameOptions          string        `yaml:"frame_options" env:"SECURITY_FRAME_OPTIONS" default:"DENY"`
+	HstsMaxAge            time.Duration `yaml:"hsts_max_age" env:"SECURITY_HSTS_MAX_AGE" default:"4320h"`
+	HstsIncludeSubdomains bool          `yaml:"hsts_include_subdomains" env:"SECURITY_HSTS_INCLUDE_SUBDOMAINS" default:"true"`
+
+	// MaxBodySize and MaxArticleBodySize are in bytes.
+	MaxBodySize        int64 `yaml:"max_body_size" env:"SECURITY_MAX_BODY_SIZE" default:"1048576"`
+	MaxArticleBodySize int64 `yaml:"max_article_body_size" env:"SECURITY_MAX_ARTICLE_BODY_SIZE" default:"5242880"`
+}
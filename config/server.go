@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	DEFAULT_READ_TIMEOUT        = 15 * time.Second
+	DEFAULT_READ_HEADER_TIMEOUT = 5 * time.Second
+	DEFAULT_WRITE_TIMEOUT       = 30 * time.Second
+	DEFAULT_IDLE_TIMEOUT        = 120 * time.Second
+	DEFAULT_SHUTDOWN_TIMEOUT    = 30 * time.Second
+)
+
+type ServerConfig struct {
+	Addr string `yaml:"addr" env:"APP_SERVER_PORT" default:":8080"`
+
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"APP_SERVER_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"APP_SERVER_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"APP_SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"APP_SERVER_IDLE_TIMEOUT"`
+
+	// ShutdownDelay is how long the server keeps accepting requests after a
+	// termination signal while readiness already fails, so load balancers can
+	// take the instance out of rotation.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"APP_SERVER_SHUTDOWN_DELAY"`
+	// ShutdownTimeout is the deadline for draining in-flight requests.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"APP_SERVER_SHUTDOWN_TIMEOUT"`
+
+	// TrustedProxies are the IPs or CIDRs whose X-Forwarded-For and X-Real-IP
+	// headers are believed. With none, the client IP is the peer address, so a
+	// client cannot pick its own rate limit bucket.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"APP_SERVER_TRUSTED_PROXIES"`
+}
+
+func (s *ServerConfig) NewServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              s.Addr,
+		Handler:           handler,
+		ReadTimeout:       durationOrDefault(s.ReadTimeout, DEFAULT_READ_TIMEOUT),
+		ReadHeaderTimeout: durationOrDefault(s.ReadHeaderTimeout, DEFAULT_READ_HEADER_TIMEOUT),
+		WriteTimeout:      durationOrDefault(s.WriteTimeout, DEFAULT_WRITE_TIMEOUT),
+		IdleTimeout:       durationOrDefault(s.IdleTimeout, DEFAULT_IDLE_TIMEOUT),
+	}
+}
+
+func (s *ServerConfig) GetShutdownTimeout() time.Duration {
+	return durationOrDefault(s.ShutdownTimeout, DEFAULT_SHUTDOWN_TIMEOUT)
+}
+
+func (s *ServerConfig) validate(validation *ValidationError) {
+	for _, proxy := range s.TrustedProxies {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			validation.Problems = append(validation.Problems,
+				fmt.Sprintf("APP_SERVER_TRUSTED_PROXIES: must be IPs or CIDRs, got %q", proxy))
+		}
+	}
+}
+
+func durationOrDefault(value, defaultValue time.Duration) time.Duration {
+	if value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
@@ -13,3 +13,36 @@ type ArticleWithExtend struct {
 	entities.Article
 	ArticleExtend
 }
+
+// CreateArticleRequest mirrors the articles table: title is VARCHAR(50).
+type CreateArticleRequest struct {
+	CategoryId  int64   `json:"category_id" binding:"required,gt=0"`
+	Title       string  `json:"title" binding:"required,max=50"`
+	Content     string  `json:"content" binding:"required"`
+	Tags        *string `json:"tags" binding:"omitempty,max=255"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+	Image       *string `json:"image" binding:"omitempty,media_url"`
+}
+
+func (r *CreateArticleRequest) ToEntity() *entities.Article {
+	return &entities.Article{
+		CategoryId:  r.CategoryId,
+		Title:       r.Title,
+		Content:     r.Content,
+		Tags:        r.Tags,
+		Description: r.Description,
+		Image:       r.Image,
+	}
+}
+
+type UpdateArticleRequest struct {
+	Id int64 `json:"id" binding:"required,gt=0"`
+	CreateArticleRequest
+}
+
+func (r *UpdateArticleRequest) ToEntity() *entities.Article {
+	article := r.CreateArticleRequest.ToEntity()
+	article.Id = r.Id
+
+	return article
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "github.com/michaelwp/goblog/entities"
+
+// CreateCategoryRequest mirrors the categories table: name is VARCHAR(50).
+type CreateCategoryRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+func (r *CreateCategoryRequest) ToEntity() *entities.Category {
+	return &entities.Category{
+		Name: r.Name,
+	}
+}
@@ -0,0 +1,33 @@
+package dto
+
+import "github.com/michaelwp/goblog/entities"
+
+// CreateUserRequest mirrors the users table: name and email are VARCHAR(100).
+// bcrypt ignores everything after 72 bytes, so longer passwords are refused.
+type CreateUserRequest struct {
+	Name     string  `json:"name" binding:"required,max=100"`
+	Email    string  `json:"email" binding:"required,email,max=100"`
+	Password string  `json:"password" binding:"required,min=8,max=72"`
+	Avatar   *string `json:"avatar" binding:"omitempty,media_url"`
+	Page     *string `json:"page" binding:"omitempty,url"`
+}
+
+func (r *CreateUserRequest) ToEntity() *entities.User {
+	return &entities.User{
+		Name:     r.Name,
+		Email:    r.Email,
+		Password: r.Password,
+		Avatar:   r.Avatar,
+		Page:     r.Page,
+	}
+}
+
+// PatchUserRequest validates a user after a merge patch was applied. The
+// stored password hash is never part of the document, so it is optional here.
+type PatchUserRequest struct {
+	Name     string  `json:"name" binding:"required,max=100"`
+	Email    string  `json:"email" binding:"required,email,max=100"`
+	Password string  `json:"password,omitempty" binding:"omitempty,min=8,max=72"`
+	Avatar   *string `json:"avatar" binding:"omitempty,media_url"`
+	Page     *string `json:"page" binding:"omitempty,url"`
+}
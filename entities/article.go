@@ -15,4 +15,5 @@ type Article struct {
 	CreatedAt   time.Time  `json:"created_at,omitempty"`
 	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
 	UpdatedBy   *int64     `json:"updated_by,omitempty"`
+	Version     int64      `json:"version,omitempty"`
 }
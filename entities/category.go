@@ -9,4 +9,5 @@ type Category struct {
 	CreatedAt time.Time  `json:"created_at,omitempty"`
 	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 	UpdatedBy *int64     `json:"updated_by,omitempty"`
+	Version   int64      `json:"version,omitempty"`
 }
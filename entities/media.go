@@ -0,0 +1,18 @@
+package entities
+
+import "time"
+
+// Media is an uploaded file. Usage counts the articles and users whose image
+// or avatar is its URL.
+type Media struct {
+	Id          int64     `json:"id,omitempty"`
+	OwnerId     int64     `json:"owner_id,omitempty"`
+	StorageKey  string    `json:"storage_key,omitempty"`
+	Url         string    `json:"url,omitempty"`
+	FileName    string    `json:"file_name,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size,omitempty"`
+	Checksum    string    `json:"checksum,omitempty"`
+	Usage       int64     `json:"usage"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+}
@@ -11,10 +11,12 @@ type User struct {
 	Password  string     `json:"password,omitempty"`
 	Online    Status     `json:"online,omitempty"`
 	Active    Status     `json:"active,omitempty"`
+	Admin     Status     `json:"-"`
 	Avatar    *string    `json:"avatar,omitempty"`
 	Page      *string    `json:"page,omitempty"`
 	CreatedBy int64      `json:"created_by,omitempty"`
 	CreatedAt time.Time  `json:"created_at,omitempty"`
 	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 	UpdatedBy *int64     `json:"updated_by,omitempty"`
+	Version   int64      `json:"version,omitempty"`
 }
@@ -0,0 +1,168 @@
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const DEFAULT_LOCALE = "en"
+
+//go:embed locales/*.yaml
+var Locales embed.FS
+
+// Catalog holds one flat key -> message map per locale, e.g.
+// "article.create.success" -> "article successfully created".
+// Messages may reference {field} and {param}, which Translate substitutes.
+type Catalog struct {
+	defaultLocale string
+	messages      map[string]map[string]string
+	locales       []string
+	matcher       language.Matcher
+}
+
+// Load reads every <locale>.yaml file at the root of fsys. defaultLocale must be
+// one of them; it is used when negotiation fails and as the fallback for keys
+// a locale does not translate.
+func Load(fsys fs.FS, defaultLocale string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, "*.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := &Catalog{
+		defaultLocale: defaultLocale,
+		messages:      map[string]map[string]string{},
+	}
+
+	for _, file := range files {
+		locale := strings.TrimSuffix(path.Base(file), ".yaml")
+		if _, err := language.Parse(locale); err != nil {
+			return nil, fmt.Errorf("locale file %s: %w", file, err)
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		messages := map[string]string{}
+		err = yaml.Unmarshal(content, &messages)
+		if err != nil {
+			return nil, fmt.Errorf("locale file %s: %w", file, err)
+		}
+
+		catalog.messages[locale] = messages
+		catalog.locales = append(catalog.locales, locale)
+	}
+
+	if _, ok := catalog.messages[defaultLocale]; !ok {
+		return nil, fmt.Errorf("default locale %q has no catalog", defaultLocale)
+	}
+
+	// the matcher falls back to its first tag, so the default locale goes first
+	sort.Slice(catalog.locales, func(i, j int) bool {
+		if catalog.locales[i] == defaultLocale || catalog.locales[j] == defaultLocale {
+			return catalog.locales[i] == defaultLocale
+		}
+
+		return catalog.locales[i] < catalog.locales[j]
+	})
+
+	tags := make([]language.Tag, 0, len(catalog.locales))
+	for _, locale := range catalog.locales {
+		tags = append(tags, language.Make(locale))
+	}
+
+	catalog.matcher = language.NewMatcher(tags)
+
+	return catalog, nil
+}
+
+// LoadDir loads the catalogs from dir, or the embedded ones when dir is empty.
+func LoadDir(dir, defaultLocale string) (*Catalog, error) {
+	if dir == "" {
+		locales, err := fs.Sub(Locales, "locales")
+		if err != nil {
+			return nil, err
+		}
+
+		return Load(locales, defaultLocale)
+	}
+
+	return Load(os.DirFS(dir), defaultLocale)
+}
+
+func (c *Catalog) DefaultLocale() string {
+	return c.defaultLocale
+}
+
+func (c *Catalog) Locales() []string {
+	return c.locales
+}
+
+// Negotiate picks the best supported locale for an Accept-Language header.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return c.defaultLocale
+	}
+
+	_, index, confidence := c.matcher.Match(tags...)
+	if confidence == language.No {
+		return c.defaultLocale
+	}
+
+	return c.locales[index]
+}
+
+// Messages returns the catalog of locale merged over the default locale,
+// so clients always receive every key.
+func (c *Catalog) Messages(locale string) (map[string]string, bool) {
+	messages, ok := c.messages[locale]
+	if !ok {
+		return nil, false
+	}
+
+	merged := make(map[string]string, len(c.messages[c.defaultLocale]))
+	for key, message := range c.messages[c.defaultLocale] {
+		merged[key] = message
+	}
+
+	for key, message := range messages {
+		merged[key] = message
+	}
+
+	return merged, true
+}
+
+// Lookup returns the message for key in locale, falling back to the default locale.
+func (c *Catalog) Lookup(locale, key string) (string, bool) {
+	if message, ok := c.messages[locale][key]; ok {
+		return message, true
+	}
+
+	message, ok := c.messages[c.defaultLocale][key]
+	return message, ok
+}
+
+// Translate returns the message for key with args substituted, or fallback
+// when no locale translates key.
+func (c *Catalog) Translate(locale, key, fallback string, args map[string]string) string {
+	message, ok := c.Lookup(locale, key)
+	if !ok {
+		return fallback
+	}
+
+	for name, value := range args {
+		message = strings.ReplaceAll(message, "{"+name+"}", value)
+	}
+
+	return message
+}
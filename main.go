@@ -1,86 +1,223 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	"github.com/michaelwp/goblog/api"
 	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/cache"
 	"github.com/michaelwp/goblog/config"
+	"github.com/michaelwp/goblog/i18n"
+	"github.com/michaelwp/goblog/metrics"
+	"github.com/michaelwp/goblog/model"
+	"github.com/michaelwp/goblog/storage"
+	"github.com/michaelwp/goblog/tracing"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 )
 
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
+const USAGE = `usage: goblog <command> [arguments]
 
-	gin.SetMode(os.Getenv(gin.EnvGinMode))
-}
+commands:
+  serve                               start the HTTP server (default)
+  migrate up|down|status|create       manage the database schema
+  user create|reset-password          manage users
+  cache flush                         evict the article caches (not the memory backend)
+  seed                                insert default categories and a sample article
+
+run "goblog <command> -h" for the flags of a command`
 
 func main() {
-	r := gin.Default()
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		RunServe(args)
+	case "migrate":
+		RunMigrate(args)
+	case "user":
+		RunUser(args)
+	case "cache":
+		RunCache(args)
+	case "seed":
+		RunSeed(args)
+	default:
+		log.Fatal(USAGE)
+	}
+}
 
-	api.NewRouter(r, SetupConfig())
+// LoadConfig registers the -config flag on flagSet, parses args and loads
+// the application configuration, exiting on error.
+func LoadConfig(flagSet *flag.FlagSet, args []string) (appConfig *config.AppConfig) {
+	configFile := flagSet.String("config", "", "path to the YAML configuration file")
+	_ = flagSet.Parse(args)
 
-	log.Println("server started on port " + os.Getenv("APP_SERVER_PORT"))
-	err := r.Run(os.Getenv("APP_SERVER_PORT"))
+	appConfig, err := config.Load(*configFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	return
 }
 
-func SetupConfig() (config *controller.Config) {
-	postgres, err := SetupPostgres()
+func SetupConfig(appConfig *config.AppConfig) (config *controller.Config) {
+	postgres, err := SetupPostgres(appConfig)
 	if err != nil {
 		log.Fatal("error connect to postgres", err)
 	}
 
-	client, err := SetupRedis()
+	replicas, err := SetupReplicas(appConfig, postgres)
+	if err != nil {
+		log.Fatal("error connect to postgres replicas", err)
+	}
+
+	client, err := SetupRedis(appConfig)
 	if err != nil {
 		log.Fatal("error connect to redis", err)
 	}
 
+	err = metrics.RegisterDB(postgres, "postgres")
+	if err != nil {
+		log.Fatal("error register postgres metrics", err)
+	}
+
+	for i, replica := range replicas {
+		err = metrics.RegisterDB(replica, "postgres_replica_"+strconv.Itoa(i))
+		if err != nil {
+			log.Fatal("error register postgres replica metrics", err)
+		}
+	}
+
+	client.AddHook(metrics.NewRedisHook())
+
+	err = redisotel.InstrumentTracing(client)
+	if err != nil {
+		log.Fatal("error instrument redis tracing", err)
+	}
+
+	catalog, err := SetupI18n(appConfig)
+	if err != nil {
+		log.Fatal("error load i18n catalog", err)
+	}
+
+	fileStorage, err := SetupStorage(appConfig)
+	if err != nil {
+		log.Fatal("error setup media storage", err)
+	}
+
+	router := model.NewReplicaRouter(postgres, replicas)
+
 	config = &controller.Config{
-		Postgres:    postgres,
-		RedisClient: client,
+		Postgres:      postgres,
+		Replicas:      router,
+		ArticleModel:  model.NewArticleModel(postgres, router),
+		UserModel:     model.NewUserModel(postgres),
+		CategoryModel: model.NewCategoryModel(postgres, router),
+		MediaModel:    model.NewMediaModel(postgres),
+		Transactor:    model.NewTransactor(postgres),
+		RedisClient:   client,
+		Storage:       fileStorage,
+		JwtSigningKey: []byte(appConfig.JwtSigningKey),
+		RateLimit:     &appConfig.RateLimit,
+		CORS:          &appConfig.CORS,
+		Security:      &appConfig.Security,
+		Idempotency:   &appConfig.Idempotency,
+		Auth:          &appConfig.Auth,
+		Media:         &appConfig.Media,
+		ProblemJSON:   appConfig.ProblemJSON,
+		I18n:          catalog,
+	}
+
+	config.Cache = SetupCache(appConfig, client, config.Go)
+
+	if len(replicas) > 0 {
+		config.ReadYourWrites = appConfig.Postgres.ReadYourWrites
+		config.Go(func(ctx context.Context) {
+			router.Monitor(ctx, appConfig.Postgres.ReplicaCheckInterval)
+		})
+	}
+
+	return
+}
+
+func SetupPostgres(appConfig *config.AppConfig) (postgres *sql.DB, err error) {
+	return appConfig.Postgres.Connect()
+}
+
+// SetupReplicas opens the read replicas, closing the primary on error.
+func SetupReplicas(appConfig *config.AppConfig, postgres *sql.DB) (replicas []*sql.DB, err error) {
+	replicas, err = appConfig.Postgres.ConnectReplicas()
+	if err != nil {
+		_ = postgres.Close()
 	}
 
 	return
 }
 
-func SetupPostgres() (postgres *sql.DB, err error) {
-	configDb := &config.PostgresDBConfig{
-		Host:    os.Getenv("POSTGRES_DB_HOST"),
-		Port:    os.Getenv("POSTGRES_DB_PORT"),
-		User:    os.Getenv("POSTGRES_DB_USER"),
-		Pass:    os.Getenv("POSTGRES_DB_PASS"),
-		Name:    os.Getenv("POSTGRES_DB_NAME"),
-		SslMode: os.Getenv("POSTGRES_DB_SSL_MODE"),
+func SetupRedis(appConfig *config.AppConfig) (client *redis.Client, err error) {
+	return appConfig.Redis.ConnectWithString()
+}
+
+func SetupCache(appConfig *config.AppConfig, client *redis.Client, background func(fn func(ctx context.Context))) cache.Cache {
+	options := cache.Options{
+		StaleTTL: appConfig.Cache.StaleTTL,
+		LockTTL:  appConfig.Cache.LockTTL,
+		LockWait: appConfig.Cache.LockWait,
+
+		BreakerThreshold: appConfig.Cache.BreakerThreshold,
+		BreakerCooldown:  appConfig.Cache.BreakerCooldown,
 	}
 
-	return configDb.Connect()
+	switch appConfig.Cache.Backend {
+	case config.CACHE_BACKEND_MEMORY:
+		return cache.NewMemoryCache(appConfig.Cache.LocalSize, appConfig.Cache.StaleTTL, background)
+	case config.CACHE_BACKEND_TIERED:
+		return cache.NewTieredCache(client, options, appConfig.Cache.LocalSize, appConfig.Cache.LocalTTL, background)
+	default:
+		return cache.NewRedisCache(client, options, background)
+	}
 }
 
-func SetupRedis() (client *redis.Client, err error) {
-	configRedis := &config.RedisDBConfig{
-		Host: os.Getenv("REDIS_HOST"),
-		Port: os.Getenv("REDIS_PORT"),
-		Pass: os.Getenv("REDIS_PASSWORD"),
-		DB:   os.Getenv("REDIS_DB"),
-		User: os.Getenv("REDIS_USER"),
+func SetupStorage(appConfig *config.AppConfig) (fileStorage storage.Storage, err error) {
+	if appConfig.Media.Backend == config.MEDIA_BACKEND_S3 {
+		fileStorage, err = storage.NewS3Storage(appConfig.Media.S3Endpoint, appConfig.Media.S3Region,
+			appConfig.Media.S3Bucket, appConfig.Media.S3AccessKey, appConfig.Media.S3SecretKey,
+			appConfig.Media.S3Client())
+	} else {
+		fileStorage, err = storage.NewLocalStorage(appConfig.Media.Dir)
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
-	return configRedis.ConnectWithString()
+	return fileStorage, nil
+}
+
+func SetupTracing(appConfig *config.AppConfig) (shutdown func(context.Context) error, err error) {
+	configTracing := &tracing.Config{
+		Exporter: appConfig.Tracing.Exporter,
+	}
+
+	return tracing.Setup(context.Background(), configTracing)
+}
+
+func SetupI18n(appConfig *config.AppConfig) (*i18n.Catalog, error) {
+	return i18n.LoadDir(appConfig.I18n.Dir, appConfig.I18n.DefaultLocale)
 }
 
-func SetupStaticFile(r *gin.Engine) {
+func SetupStaticFile(r *gin.Engine, appConfig *config.AppConfig) {
 	var fileSystem http.FileSystem
-	fileSystem = http.Dir(os.Getenv("APP_CLIENT_FILE"))
+	fileSystem = http.Dir(appConfig.ClientFile)
 
 	r.Group("/")
 	r.NoRoute(func(c *gin.Context) {
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const NAMESPACE = "goblog"
+
+const (
+	CACHE_ARTICLE_LIST = "articleList"
+	CACHE_ARTICLE      = "article"
+)
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HttpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	RedisCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Redis command latency by command and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "result"})
+
+	CacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache name and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	CacheCircuitOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "cache_circuit_open",
+		Help:      "1 while the cache circuit breaker is open and Redis is bypassed.",
+	})
+
+	LoginTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "login_total",
+		Help:      "Login attempts by result (success or failure).",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HttpRequestsTotal,
+		HttpRequestDuration,
+		RedisCommandDuration,
+		CacheRequestsTotal,
+		CacheCircuitOpen,
+		LoginTotal,
+	)
+}
+
+// RegisterDB exposes the connection pool statistics of db (sql.DB.Stats).
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+func CacheHit(cache string) {
+	CacheRequestsTotal.WithLabelValues(cache, "hit").Inc()
+}
+
+func CacheMiss(cache string) {
+	CacheRequestsTotal.WithLabelValues(cache, "miss").Inc()
+}
+
+func LoginSuccess() {
+	LoginTotal.WithLabelValues("success").Inc()
+}
+
+func LoginFailure() {
+	LoginTotal.WithLabelValues("failure").Inc()
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"net"
+	"time"
+)
+
+// RedisHook records the latency of every command sent through a redis.Client.
+type RedisHook struct{}
+
+func NewRedisHook() redis.Hook {
+	return RedisHook{}
+}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+
+		RedisCommandDuration.WithLabelValues(cmd.Name(), redisResult(err)).
+			Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+
+		RedisCommandDuration.WithLabelValues("pipeline", redisResult(err)).
+			Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+func redisResult(err error) string {
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "error"
+	}
+
+	return "ok"
+}
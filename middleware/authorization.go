@@ -4,70 +4,67 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/config"
 	"github.com/michaelwp/goblog/tool"
-	"net/http"
+	"github.com/redis/go-redis/v9"
 	"strconv"
 	"strings"
 )
 
 func AuthMiddleware(config *controller.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		response := &controller.Response{
-			Status:    controller.ERROR,
-			Message:   "unauthorized",
-			Translate: "unauthorized",
-			HttpCode:  http.StatusUnauthorized,
-		}
-
 		bearerToken := c.Request.Header.Get("Authorization")
 		if bearerToken == "" {
-			response.Message = tool.PrintLog("get header authorization", errors.New("token required")).Error()
-			c.JSON(http.StatusUnauthorized, response)
-			c.Abort()
+			controller.AbortWithError(c, controller.ErrUnauthorized.Wrap(errors.New("token required")))
 			return
 		}
 
 		bearerTokenSplit := strings.Split(bearerToken, " ")
 		if len(bearerTokenSplit) < 2 {
-			response.Message = tool.PrintLog("bearer token split", errors.New("token required")).Error()
-			c.JSON(http.StatusUnauthorized, response)
-			c.Abort()
+			controller.AbortWithError(c, controller.ErrUnauthorized.Wrap(errors.New("bearer token required")))
 			return
 		}
 
 		token := bearerTokenSplit[1]
 
-		claims, err := tool.VerifyJWT(token)
+		claims, err := tool.VerifyJWT(token, config.JwtSigningKey)
 		if err != nil {
-			response.Message = tool.PrintLog("verify JWT", err).Error()
-			c.JSON(http.StatusUnauthorized, response)
-			c.Abort()
+			controller.AbortWithError(c, controller.ErrUnauthorized.Wrap(err))
+			return
+		}
+
+		userIdFloat, ok := claims["id"].(float64)
+		if !ok {
+			controller.AbortWithError(c, controller.ErrUnauthorized.Wrap(errors.New("token has no user id")))
 			return
 		}
 
-		userIdFloat := claims["id"].(float64)
 		userIdStr := strconv.FormatUint(uint64(userIdFloat), 10)
 
 		resultToken, err := config.RedisClient.Get(c, userIdStr).Result()
-		if err != nil {
-			response.Message = tool.PrintLog("get redis token", err).Error()
-			c.JSON(http.StatusUnauthorized, response)
-			c.Abort()
+		switch {
+		case errors.Is(err, redis.Nil):
+			controller.AbortWithError(c, controller.ErrUnauthorized.Wrap(errors.New("session not found")))
 			return
+		case err != nil:
+			if !trustTokenWithoutSession(config.Auth) {
+				controller.AbortWithError(c, controller.ErrSessionUnavailable.Wrap(err))
+				return
+			}
+
+			// the token is signed and unexpired, trust it until the session store is back
+			tool.PrintLog("auth session store", err)
+			resultToken = token
 		}
 
 		if resultToken != token {
-			response.Message = tool.PrintLog("verify token with redis", errors.New("token invalid")).Error()
-			c.JSON(http.StatusUnauthorized, response)
-			c.Abort()
+			controller.AbortWithError(c, controller.ErrUnauthorized.Wrap(errors.New("token invalid")))
 			return
 		}
 
 		userIdInt, err := strconv.ParseInt(userIdStr, 10, 64)
-		if resultToken != token {
-			response.Message = tool.PrintLog("parseInt userId", err).Error()
-			c.JSON(http.StatusInternalServerError, response)
-			c.Abort()
+		if err != nil {
+			controller.AbortWithError(c, controller.ErrInternal.Wrap(err))
 			return
 		}
 
@@ -75,3 +72,9 @@ func AuthMiddleware(config *controller.Config) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// trustTokenWithoutSession reports whether a signed token is accepted while
+// the session store is unreachable.
+func trustTokenWithoutSession(auth *config.AuthConfig) bool {
+	return auth != nil && auth.SessionStoreDown == config.SESSION_STORE_DOWN_ALLOW
+}
@@ -1,19 +1,70 @@
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/config"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func CORSMiddleware(cors *config.CORSConfig) gin.HandlerFunc {
+	allowedMethods := strings.Join(cors.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(cors.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(cors.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cors.MaxAge.Seconds()))
+
+	// with credentials the origin is echoed back, so it must never be "*"
+	echoOrigin := cors.AllowCredentials || !cors.AllowsAnyOrigin()
 
-func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		origin := c.Request.Header.Get("Origin")
+		preflight := c.Request.Method == http.MethodOptions &&
+			c.Request.Header.Get("Access-Control-Request-Method") != ""
+
+		if echoOrigin {
+			// the response depends on the Origin header, shared caches must key on it
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+
+		if origin == "" || !cors.IsOriginAllowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+
+			c.Next()
+			return
+		}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		if echoOrigin {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if cors.AllowCredentials {
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+			if allowedHeaders != "" {
+				c.Writer.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			}
+
+			c.Writer.Header().Set("Access-Control-Max-Age", maxAge)
+
+			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
+		if exposedHeaders != "" {
+			c.Writer.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+		}
+
 		c.Next()
 	}
 }
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api/controller"
+)
+
+// ErrorMiddleware renders the last error a handler reported with c.Error,
+// unless a response has already been written.
+func ErrorMiddleware(config *controller.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		controller.RenderError(c, c.Errors.Last().Err, config)
+	}
+}
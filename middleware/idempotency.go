@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/config"
+	"github.com/michaelwp/goblog/tool"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"net/http"
+)
+
+const IDEMPOTENCY_KEY_MAX_LENGTH = 255
+
+// IDEMPOTENCY_ACQUIRE_ATTEMPTS bounds how often a request tries to take a key
+// that disappears between SETNX and GET, because the first attempt failed or
+// its record expired.
+const IDEMPOTENCY_ACQUIRE_ATTEMPTS = 3
+
+// idempotencyRecord is stored under the key while the first request runs
+// (Status 0) and then holds its response for replay.
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	r.body.WriteString(data)
+	return r.ResponseWriter.WriteString(data)
+}
+
+// IdempotencyMiddleware replays the stored response when a request is retried
+// with the same Idempotency-Key. Keys are scoped to the caller and the route,
+// reusing one with a different body answers 422. Failed attempts (errors and
+// 5xx) are not stored so they can be retried. Like the rate limiter, it lets
+// requests through when Redis is unavailable.
+func IdempotencyMiddleware(config *controller.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader("Idempotency-Key")
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > IDEMPOTENCY_KEY_MAX_LENGTH {
+			controller.AbortWithError(c, controller.ErrIdempotencyKey)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			controller.AbortWithError(c, controller.ErrInvalidBody.Wrap(err))
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := sha256.Sum256(append([]byte(c.Request.Method+" "+c.FullPath()+"\n"), body...))
+		record := &idempotencyRecord{Fingerprint: hex.EncodeToString(fingerprint[:])}
+
+		keyHash := sha256.Sum256([]byte(idempotencyKey))
+		key := "idempotency:" + idempotencyScope(c) + ":" + hex.EncodeToString(keyHash[:])
+
+		processing, _ := json.Marshal(record)
+		acquired := false
+		for attempt := 0; attempt < IDEMPOTENCY_ACQUIRE_ATTEMPTS && !acquired; attempt++ {
+			acquired, err = config.RedisClient.SetNX(c, key, processing, config.Idempotency.LockTTL).Result()
+			if err != nil {
+				tool.PrintLog("idempotency", err)
+				c.Next()
+				return
+			}
+
+			if !acquired && replayIdempotent(c, config, key, record.Fingerprint) {
+				return
+			}
+		}
+
+		if !acquired {
+			controller.AbortWithError(c, controller.ErrIdempotencyInProgress)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		if len(c.Errors) > 0 || !recorder.Written() || recorder.Status() >= http.StatusInternalServerError {
+			err = config.RedisClient.Del(c, key).Err()
+			if err != nil {
+				tool.PrintLog("idempotency", err)
+			}
+
+			return
+		}
+
+		record.Status = recorder.Status()
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Body = recorder.body.Bytes()
+
+		completed, _ := json.Marshal(record)
+		err = config.RedisClient.Set(c, key, completed, config.Idempotency.Window).Err()
+		if err != nil {
+			tool.PrintLog("idempotency", err)
+		}
+	}
+}
+
+// idempotencyScope keeps keys of different callers and routes apart.
+func idempotencyScope(c *gin.Context) string {
+	return rateLimitIdentity(c, config.RATE_LIMIT_IDENTITY_USER) + ":" + c.FullPath()
+}
+
+// replayIdempotent answers from the record stored under key. It reports false
+// without answering when there is none anymore, the key may then be acquired.
+func replayIdempotent(c *gin.Context, config *controller.Config, key, fingerprint string) (answered bool) {
+	stored, err := config.RedisClient.Get(c, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// the first attempt failed or expired in between
+		return false
+	}
+
+	var record idempotencyRecord
+	if err == nil {
+		err = json.Unmarshal(stored, &record)
+	}
+
+	if err != nil {
+		tool.PrintLog("idempotency", err)
+		controller.AbortWithError(c, controller.ErrInternal.Wrap(err))
+		return true
+	}
+
+	if record.Fingerprint != fingerprint {
+		controller.AbortWithError(c, controller.ErrIdempotencyKeyReused)
+		return true
+	}
+
+	if record.Status == 0 {
+		controller.AbortWithError(c, controller.ErrIdempotencyInProgress)
+		return true
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(record.Status, record.ContentType, record.Body)
+	c.Abort()
+	return true
+}
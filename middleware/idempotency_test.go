@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyRouter counts the calls of each handler behind IdempotencyMiddleware.
+type idempotencyRouter struct {
+	*gin.Engine
+	redisServer *miniredis.Miniredis
+	redisClient *redis.Client
+	calls       atomic.Int64
+	started     chan struct{}
+	release     chan struct{}
+}
+
+func newIdempotencyRouter(t *testing.T) *idempotencyRouter {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := &idempotencyRouter{
+		Engine:      gin.New(),
+		redisServer: miniredis.RunT(t),
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+
+	router.redisClient = redis.NewClient(&redis.Options{Addr: router.redisServer.Addr()})
+	t.Cleanup(func() {
+		_ = router.redisClient.Close()
+	})
+
+	testConfig := &controller.Config{
+		RedisClient: router.redisClient,
+		Idempotency: &config.IdempotencyConfig{Window: time.Hour, LockTTL: time.Minute},
+	}
+
+	router.Use(ErrorMiddleware(testConfig), IdempotencyMiddleware(testConfig))
+	router.POST("/created", func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"call": router.calls.Add(1)})
+	})
+	router.POST("/failed", func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{"call": router.calls.Add(1)})
+	})
+	router.POST("/error", func(c *gin.Context) {
+		router.calls.Add(1)
+		controller.AbortWithError(c, controller.ErrValidation)
+	})
+	router.POST("/slow", func(c *gin.Context) {
+		router.started <- struct{}{}
+		<-router.release
+		c.JSON(http.StatusCreated, gin.H{"call": router.calls.Add(1)})
+	})
+
+	return router
+}
+
+func (router *idempotencyRouter) post(path, key, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.RemoteAddr = "198.51.100.7:1234"
+	request.Header.Set("Idempotency-Key", key)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	router := newIdempotencyRouter(t)
+
+	first := router.post("/created", "key-1", `{"title": "Hello"}`)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first: status = %d, replayed %q", first.Code, first.Header().Get("Idempotent-Replayed"))
+	}
+
+	replay := router.post("/created", "key-1", `{"title": "Hello"}`)
+	if replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" ||
+		replay.Body.String() != first.Body.String() {
+		t.Fatalf("replay: status = %d, body %s", replay.Code, replay.Body.String())
+	}
+
+	reused := router.post("/created", "key-1", `{"title": "Other"}`)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key: status = %d, want 422", reused.Code)
+	}
+
+	if calls := router.calls.Load(); calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+}
+
+func TestIdempotencyRejectsRequestInProgress(t *testing.T) {
+	router := newIdempotencyRouter(t)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- router.post("/slow", "key-1", `{}`)
+	}()
+
+	<-router.started
+
+	// the processing record holds the key until the first request completes
+	concurrent := router.post("/slow", "key-1", `{}`)
+	if concurrent.Code != http.StatusConflict || !strings.Contains(concurrent.Body.String(),
+		controller.ErrIdempotencyInProgress.Code) {
+		t.Fatalf("concurrent: status = %d, body %s", concurrent.Code, concurrent.Body.String())
+	}
+
+	close(router.release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d, want 201", first.Code)
+	}
+
+	replay := router.post("/slow", "key-1", `{}`)
+	if replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay: status = %d, replayed %q", replay.Code, replay.Header().Get("Idempotent-Replayed"))
+	}
+}
+
+func TestIdempotencyDoesNotStoreFailures(t *testing.T) {
+	for path, status := range map[string]int{
+		"/failed": http.StatusInternalServerError,
+		"/error":  http.StatusUnprocessableEntity,
+	} {
+		router := newIdempotencyRouter(t)
+
+		for i := 1; i <= 2; i++ {
+			recorder := router.post(path, "key-1", `{}`)
+			if recorder.Code != status || recorder.Header().Get("Idempotent-Replayed") != "" {
+				t.Fatalf("%s attempt %d: status = %d, replayed %q", path, i, recorder.Code,
+					recorder.Header().Get("Idempotent-Replayed"))
+			}
+
+			if calls := router.calls.Load(); calls != int64(i) {
+				t.Fatalf("%s attempt %d: handler ran %d times", path, i, calls)
+			}
+		}
+
+		if keys := router.redisServer.Keys(); len(keys) != 0 {
+			t.Fatalf("%s: stored keys %q", path, keys)
+		}
+	}
+}
+
+// expiringHook deletes every key once right after a SET NX lost, as if the
+// record it lost against expired before the GET.
+type expiringHook struct {
+	server  *miniredis.Miniredis
+	expired atomic.Bool
+}
+
+func (h *expiringHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *expiringHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+
+		if acquire, ok := cmd.(*redis.BoolCmd); ok && err == nil && !acquire.Val() && !h.expired.Swap(true) {
+			h.server.FlushAll()
+		}
+
+		return err
+	}
+}
+
+func (h *expiringHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestIdempotencyAcquiresKeyExpiredAfterSetNX(t *testing.T) {
+	router := newIdempotencyRouter(t)
+
+	first := router.post("/created", "key-1", `{}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d", first.Code)
+	}
+
+	router.redisClient.AddHook(&expiringHook{server: router.redisServer})
+
+	retried := router.post("/created", "key-1", `{}`)
+	if retried.Code != http.StatusCreated || retried.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("after expiry: status = %d, replayed %q, want a new 201", retried.Code,
+			retried.Header().Get("Idempotent-Replayed"))
+	}
+
+	if calls := router.calls.Load(); calls != 2 {
+		t.Fatalf("handler ran %d times, want twice", calls)
+	}
+
+	if body := retried.Body.String(); body != `{"call":2}` {
+		t.Fatalf("body = %s", body)
+	}
+}
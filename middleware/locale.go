@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/i18n"
+)
+
+// LocaleMiddleware negotiates the response locale from Accept-Language
+// and stores it for controller.Config.Localize.
+func LocaleMiddleware(catalog *i18n.Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if catalog == nil {
+			c.Next()
+			return
+		}
+
+		locale := catalog.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(controller.LOCALE, locale)
+		c.Header("Content-Language", locale)
+		c.Writer.Header().Add("Vary", "Accept-Language")
+
+		c.Next()
+	}
+}
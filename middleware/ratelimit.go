@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/config"
+	"github.com/michaelwp/goblog/tool"
+	"github.com/redis/go-redis/v9"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimitProcess and rateLimitSequence make sorted-set members unique, two
+// requests in the same nanosecond, on one instance or several, would
+// otherwise be counted once.
+var (
+	rateLimitProcess  = newRateLimitProcess()
+	rateLimitSequence atomic.Uint64
+)
+
+func newRateLimitProcess() string {
+	random := make([]byte, 8)
+	_, _ = rand.Read(random)
+
+	return hex.EncodeToString(random)
+}
+
+// rateLimitMember is the sorted-set member recording a request at now.
+func rateLimitMember(now time.Time) string {
+	return strconv.FormatInt(now.UnixNano(), 10) + ":" + rateLimitProcess + ":" +
+		strconv.FormatUint(rateLimitSequence.Add(1), 10)
+}
+
+// slidingWindowScript keeps one sorted-set member per request inside the window.
+// It returns {allowed, remaining, retry after in milliseconds}.
+var slidingWindowScript = redis.NewScript(`
+	local key = KEYS[1]
+	local now = tonumber(ARGV[1])
+	local window = tonumber(ARGV[2])
+	local limit = tonumber(ARGV[3])
+	local member = ARGV[4]
+
+	redis.call("ZREMRANGEBYSCORE", key, 0, now - window)
+
+	local count = redis.call("ZCARD", key)
+	if count < limit then
+		redis.call("ZADD", key, now, member)
+		redis.call("PEXPIRE", key, window)
+		return {1, limit - count - 1, 0}
+	end
+
+	local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+	local retryAfter = window
+	if oldest[2] then
+		retryAfter = tonumber(oldest[2]) + window - now
+	end
+
+	return {0, 0, retryAfter}
+`)
+
+func RateLimitMiddleware(config *controller.Config, rule *config.RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rule.Limit <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		key := fmt.Sprintf("ratelimit:%s:%s", rule.Name, rateLimitIdentity(c, rule.Identity))
+		member := rateLimitMember(now)
+
+		result, err := slidingWindowScript.Run(c, config.RedisClient, []string{key},
+			now.UnixMilli(), rule.Window.Milliseconds(), rule.Limit, member).Int64Slice()
+		if err != nil {
+			// the limiter must not take the API down with Redis, let the request through
+			tool.PrintLog("rate limit", err)
+			c.Next()
+			return
+		}
+
+		allowed, remaining, retryAfterMs := result[0] == 1, result[1], result[2]
+		resetAt := now.Add(rule.Window)
+		if !allowed {
+			resetAt = now.Add(time.Duration(retryAfterMs) * time.Millisecond)
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rule.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+
+		if !allowed {
+			retryAfter := int64(math.Ceil(float64(retryAfterMs) / 1000))
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+
+			controller.AbortWithError(c, controller.ErrTooManyRequests)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitIdentity falls back to the client IP when the requested identity
+// is not available, e.g. a user id on a request that is not authenticated.
+func rateLimitIdentity(c *gin.Context, identity string) string {
+	switch identity {
+	case config.RATE_LIMIT_IDENTITY_USER:
+		if userId, err := controller.GetCurrentUserIdLoggedIn(c); err == nil {
+			return "user:" + strconv.FormatInt(userId, 10)
+		}
+	case config.RATE_LIMIT_IDENTITY_TOKEN:
+		bearerTokenSplit := strings.Split(c.Request.Header.Get("Authorization"), " ")
+		if len(bearerTokenSplit) >= 2 && bearerTokenSplit[1] != "" {
+			// hash the token so it never appears in Redis keys
+			sum := sha256.Sum256([]byte(bearerTokenSplit[1]))
+			return "token:" + hex.EncodeToString(sum[:])
+		}
+	}
+
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// newRateLimitRouter limits GET / by client IP, trusting the X-Forwarded-For
+// of trustedProxies only.
+func newRateLimitRouter(t *testing.T, rule *config.RateLimitRule, trustedProxies []string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	redisServer := miniredis.RunT(t)
+	testConfig := &controller.Config{
+		RedisClient: redis.NewClient(&redis.Options{Addr: redisServer.Addr()}),
+	}
+
+	router := gin.New()
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatal(err)
+	}
+
+	router.Use(ErrorMiddleware(testConfig))
+	router.GET("/", RateLimitMiddleware(testConfig, rule), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+func rateLimitRequest(router *gin.Engine, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestRateLimitRejectsOverLimit(t *testing.T) {
+	rule := &config.RateLimitRule{Name: "login", Limit: 2, Window: time.Minute, Identity: config.RATE_LIMIT_IDENTITY_IP}
+	router := newRateLimitRouter(t, rule, nil)
+
+	for i := 0; i < rule.Limit; i++ {
+		recorder := rateLimitRequest(router, "198.51.100.7:1234", "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i, recorder.Code)
+		}
+
+		if remaining := recorder.Header().Get("X-RateLimit-Remaining"); remaining != strconv.Itoa(rule.Limit-i-1) {
+			t.Fatalf("request %d: remaining = %q", i, remaining)
+		}
+	}
+
+	recorder := rateLimitRequest(router, "198.51.100.7:1234", "")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", recorder.Code)
+	}
+
+	retryAfter, err := strconv.Atoi(recorder.Header().Get("Retry-After"))
+	if err != nil || retryAfter <= 0 || retryAfter > int(rule.Window.Seconds()) {
+		t.Fatalf("Retry-After = %q", recorder.Header().Get("Retry-After"))
+	}
+
+	// other clients have their own bucket
+	recorder = rateLimitRequest(router, "198.51.100.8:1234", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("other client: status = %d, want 200", recorder.Code)
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	rule := &config.RateLimitRule{Name: "login", Limit: 2, Window: time.Minute, Identity: config.RATE_LIMIT_IDENTITY_IP}
+	router := newRateLimitRouter(t, rule, nil)
+
+	for i := 0; i < rule.Limit; i++ {
+		rateLimitRequest(router, "198.51.100.7:1234", "203.0.113."+strconv.Itoa(i))
+	}
+
+	recorder := rateLimitRequest(router, "198.51.100.7:1234", "203.0.113.99")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("fresh X-Forwarded-For: status = %d, want 429", recorder.Code)
+	}
+}
+
+func TestRateLimitTrustsConfiguredProxy(t *testing.T) {
+	rule := &config.RateLimitRule{Name: "login", Limit: 1, Window: time.Minute, Identity: config.RATE_LIMIT_IDENTITY_IP}
+	router := newRateLimitRouter(t, rule, []string{"10.0.0.0/8"})
+
+	for _, client := range []string{"203.0.113.1", "203.0.113.2"} {
+		recorder := rateLimitRequest(router, "10.0.0.5:1234", client)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("client %s behind the proxy: status = %d, want 200", client, recorder.Code)
+		}
+	}
+
+	recorder := rateLimitRequest(router, "10.0.0.5:1234", "203.0.113.1")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("repeated client behind the proxy: status = %d, want 429", recorder.Code)
+	}
+}
+
+func TestRateLimitCountsRequestsOfTheSameInstant(t *testing.T) {
+	now := time.Now()
+	if first, second := rateLimitMember(now), rateLimitMember(now); first == second {
+		t.Fatalf("members of one instant collide: %q", first)
+	}
+}
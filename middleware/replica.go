@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/model"
+	"github.com/michaelwp/goblog/tool"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const READ_YOUR_WRITES_PREFIX = "primary:user:"
+
+// ReadYourWritesMiddleware routes the reads of a user to the primary for
+// config.ReadYourWrites after their last successful write, so they see it
+// even on a lagging replica. Writes always read the primary, they compare
+// versions read in the same request. The user is taken from the bearer token
+// without a session lookup, public routes are not authenticated.
+func ReadYourWritesMiddleware(config *controller.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.ReadYourWrites <= 0 {
+			c.Next()
+			return
+		}
+
+		write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead &&
+			c.Request.Method != http.MethodOptions
+
+		userId, identified := bearerUserId(c, config.JwtSigningKey)
+		key := READ_YOUR_WRITES_PREFIX + strconv.FormatInt(userId, 10)
+
+		switch {
+		case write:
+			c.Set(model.READ_PRIMARY, true)
+		case identified:
+			sticky, err := config.RedisClient.Exists(c, key).Result()
+			if err != nil {
+				// the primary is always consistent, only slower
+				tool.PrintLog("read your writes", err)
+			}
+
+			c.Set(model.READ_PRIMARY, err != nil || sticky > 0)
+		}
+
+		c.Next()
+
+		// the error is rendered by ErrorMiddleware after this returns
+		if write && identified && len(c.Errors) == 0 && c.Writer.Status() < http.StatusBadRequest {
+			err := config.RedisClient.Set(c, key, 1, config.ReadYourWrites).Err()
+			if err != nil {
+				tool.PrintLog("read your writes", err)
+			}
+		}
+	}
+}
+
+// bearerUserId returns the user of a validly signed bearer token.
+func bearerUserId(c *gin.Context, signingKey []byte) (userId int64, ok bool) {
+	bearerTokenSplit := strings.Split(c.Request.Header.Get("Authorization"), " ")
+	if len(bearerTokenSplit) < 2 || bearerTokenSplit[1] == "" {
+		return 0, false
+	}
+
+	claims, err := tool.VerifyJWT(bearerTokenSplit[1], signingKey)
+	if err != nil {
+		return 0, false
+	}
+
+	userIdFloat, ok := claims["id"].(float64)
+	if !ok {
+		return 0, false
+	}
+
+	return int64(userIdFloat), true
+}
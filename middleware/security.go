@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api/controller"
+	"github.com/michaelwp/goblog/config"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+func SecurityHeadersMiddleware(security *config.SecurityConfig) gin.HandlerFunc {
+	hsts := ""
+	if security.HstsMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(security.HstsMaxAge.Seconds()))
+		if security.HstsIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+
+		if security.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", security.ContentSecurityPolicy)
+		}
+
+		if security.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", security.ReferrerPolicy)
+		}
+
+		if security.FrameOptions != "" {
+			header.Set("X-Frame-Options", security.FrameOptions)
+		}
+
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+
+		c.Next()
+	}
+}
+
+// BodyLimitMiddleware rejects request bodies larger than maxBytes with 413.
+// The body is buffered up to the limit so chunked requests, which carry no
+// Content-Length, are rejected before any handler reads them.
+func BodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tooLarge := controller.ErrBodyTooLarge.Wrap(
+			errors.New("request body exceeds " + strconv.FormatInt(maxBytes, 10) + " bytes"))
+
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > maxBytes {
+			controller.AbortWithError(c, tooLarge)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+		if err != nil {
+			controller.AbortWithError(c, controller.ErrInvalidBody.Wrap(err))
+			return
+		}
+
+		if int64(len(body)) > maxBytes {
+			controller.AbortWithError(c, tooLarge)
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
@@ -0,0 +1,13 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// TracingMiddleware starts a server span for every request, continuing the
+// trace found in the incoming W3C traceparent header if there is one.
+func TracingMiddleware() gin.HandlerFunc {
+	return otelgin.Middleware(tracing.SERVICE_NAME)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/michaelwp/goblog/config"
+	"github.com/michaelwp/goblog/migrations"
+	"log"
+	"strconv"
+)
+
+const MIGRATE_USAGE = "usage: goblog migrate [-config file] up|down [steps]|status|create <name>"
+
+func RunMigrate(args []string) {
+	flagSet := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configFile := flagSet.String("config", "", "path to the YAML configuration file")
+	dir := flagSet.String("dir", "migrations", "directory where create writes new migrations")
+	_ = flagSet.Parse(args)
+
+	if flagSet.NArg() < 1 {
+		log.Fatal(MIGRATE_USAGE)
+	}
+
+	command := flagSet.Arg(0)
+
+	if command == "create" {
+		if flagSet.NArg() < 2 {
+			log.Fatal(MIGRATE_USAGE)
+		}
+
+		created, err := migrations.Create(*dir, flagSet.Arg(1))
+		if err != nil {
+			log.Fatal("error create migration: ", err)
+		}
+
+		for _, path := range created {
+			fmt.Println("created", path)
+		}
+
+		return
+	}
+
+	appConfig, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	postgres, err := SetupPostgres(appConfig)
+	if err != nil {
+		log.Fatal("error connect to postgres", err)
+	}
+
+	defer func() {
+		_ = postgres.Close()
+	}()
+
+	migrator, err := migrations.NewMigrator(postgres)
+	if err != nil {
+		log.Fatal("error load migrations: ", err)
+	}
+
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal("error migrate up: ", err)
+		}
+
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+
+		fmt.Printf("%d migration(s) applied\n", len(applied))
+	case "down":
+		steps := 1
+		if flagSet.NArg() > 1 {
+			steps, err = strconv.Atoi(flagSet.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatal(MIGRATE_USAGE)
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatal("error migrate down: ", err)
+		}
+
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+
+		fmt.Printf("%d migration(s) reverted\n", len(reverted))
+	case "status":
+		statusList, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal("error migrate status: ", err)
+		}
+
+		for _, status := range statusList {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, appliedAt)
+		}
+	default:
+		log.Fatal(MIGRATE_USAGE)
+	}
+}
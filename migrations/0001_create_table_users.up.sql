@@ -6,8 +6,9 @@ CREATE TABLE IF NOT EXISTS users (
     , online BOOLEAN NOT NULL DEFAULT FALSE
     , active BOOLEAN NOT NULL DEFAULT TRUE
     , avatar TEXT NULL
+    , page TEXT NULL
     , created_by BIGINT NOT NULL
     , created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
     , updated_by BIGINT NULL
     , updated_at TIMESTAMP WITH TIME ZONE NULL
-)
\ No newline at end of file
+);
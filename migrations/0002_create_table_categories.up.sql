@@ -7,4 +7,4 @@ CREATE TABLE IF NOT EXISTS categories (
     , updated_at TIMESTAMP WITH TIME ZONE NULL
     , CONSTRAINT users_id_created_by FOREIGN KEY (created_by) REFERENCES users (id)
     , CONSTRAINT users_id_updated_by FOREIGN KEY (updated_by) REFERENCES users (id)
-)
\ No newline at end of file
+);
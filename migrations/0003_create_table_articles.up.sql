@@ -5,7 +5,7 @@ CREATE TABLE IF NOT EXISTS articles (
     , content TEXT NOT NULL
     , title VARCHAR(50) NOT NULL
     , tags TEXT NULL
-    , page TEXT NULL
+    , image TEXT NULL
     , description TEXT NULL
     , created_by BIGINT NOT NULL
     , created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
@@ -15,4 +15,4 @@ CREATE TABLE IF NOT EXISTS articles (
     , CONSTRAINT users_id_created_by FOREIGN KEY (created_by) REFERENCES users (id)
     , CONSTRAINT users_id_updated_by FOREIGN KEY (updated_by) REFERENCES users (id)
     , CONSTRAINT categories_id_category_id FOREIGN KEY (category_id) REFERENCES categories (id)
-)
\ No newline at end of file
+);
@@ -0,0 +1,2 @@
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS page TEXT NULL;
+UPDATE articles SET page = image WHERE page IS NULL;
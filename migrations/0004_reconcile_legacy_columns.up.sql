@@ -0,0 +1,18 @@
+-- databases created by hand from the old scripts have no users.page
+-- and an articles.page column where the code expects articles.image
+ALTER TABLE users ADD COLUMN IF NOT EXISTS page TEXT NULL;
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS image TEXT NULL;
+
+-- keep the images stored under the old name before dropping it
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'articles' AND column_name = 'page'
+    ) THEN
+        UPDATE articles SET image = page WHERE image IS NULL;
+    END IF;
+END
+$$;
+
+ALTER TABLE articles DROP COLUMN IF EXISTS page;
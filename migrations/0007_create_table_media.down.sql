@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_avatar;
+DROP INDEX IF EXISTS articles_image;
+DROP TABLE IF EXISTS media;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS media (
+    id BIGSERIAL PRIMARY KEY
+    , owner_id BIGINT NOT NULL
+    , storage_key TEXT NOT NULL
+    , url TEXT NOT NULL
+    , file_name VARCHAR(255) NOT NULL
+    , content_type VARCHAR(100) NOT NULL
+    , size BIGINT NOT NULL
+    , checksum CHAR(64) NOT NULL
+    , created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , CONSTRAINT media_storage_key_key UNIQUE (storage_key)
+    , CONSTRAINT users_id_owner_id FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+
+-- a user uploading the same file twice gets the first media back
+CREATE UNIQUE INDEX IF NOT EXISTS media_owner_id_checksum ON media (owner_id, checksum);
+CREATE INDEX IF NOT EXISTS media_url ON media (url);
+CREATE INDEX IF NOT EXISTS articles_image ON articles (image);
+CREATE INDEX IF NOT EXISTS users_avatar ON users (avatar);
@@ -0,0 +1,318 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// ADVISORY_LOCK_KEY is an arbitrary application-wide key for pg_advisory_lock,
+// it keeps concurrent instances from migrating at the same time.
+const ADVISORY_LOCK_KEY = 7_412_905_301
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []*Migration
+}
+
+func NewMigrator(db *sql.DB) (migrator *Migrator, err error) {
+	migrationList, err := Load(files)
+	if err != nil {
+		return
+	}
+
+	return &Migrator{DB: db, Migrations: migrationList}, nil
+}
+
+// Load reads every NNNN_name.up.sql / NNNN_name.down.sql pair from fsys,
+// sorted by version.
+func Load(fsys fs.FS) (migrationList []*Migration, err error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrationList = make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+
+		migrationList = append(migrationList, migration)
+	}
+
+	sort.Slice(migrationList, func(i, j int) bool {
+		return migrationList[i].Version < migrationList[j].Version
+	})
+
+	return
+}
+
+// Up applies every pending migration, each in its own transaction.
+func (m *Migrator) Up(ctx context.Context) (applied []*Migration, err error) {
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		appliedAt, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := appliedAt[migration.Version]; ok {
+				continue
+			}
+
+			err = m.apply(ctx, conn, migration, migration.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+				migration.Version, migration.Name)
+			if err != nil {
+				return err
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// Down rolls back the last `steps` applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted []*Migration, err error) {
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		appliedAt, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.Migrations[i]
+			if _, ok := appliedAt[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+			}
+
+			err = m.apply(ctx, conn, migration, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`,
+				migration.Version)
+			if err != nil {
+				return err
+			}
+
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+func (m *Migrator) Status(ctx context.Context) (statusList []*MigrationStatus, err error) {
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		appliedAt, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			status := &MigrationStatus{Migration: *migration}
+			if at, ok := appliedAt[migration.Version]; ok {
+				status.AppliedAt = &at
+			}
+
+			statusList = append(statusList, status)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration *Migration, script string,
+	bookkeeping string, args ...any) (err error) {
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, bookkeeping, args...)
+	if err != nil {
+		return
+	}
+
+	return tx.Commit()
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, ADVISORY_LOCK_KEY)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		_, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, ADVISORY_LOCK_KEY)
+		err = errors.Join(err, unlockErr)
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY
+			, name TEXT NOT NULL
+			, applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (appliedAt map[int64]time.Time, err error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	appliedAt = make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return
+		}
+
+		appliedAt[version] = at
+	}
+
+	return appliedAt, rows.Err()
+}
+
+// Create writes an empty up/down pair to dir, numbered after the highest
+// existing migration in that directory.
+func Create(dir, name string) (created []string, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return nil, errors.New("migration name required")
+	}
+
+	migrationList, err := Load(os.DirFS(dir))
+	if err != nil {
+		return
+	}
+
+	var version int64 = 1
+	if len(migrationList) > 0 {
+		version = migrationList[len(migrationList)-1].Version + 1
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+
+		err = os.WriteFile(path, []byte("-- "+direction+" migration for "+name+"\n"), 0o644)
+		if err != nil {
+			return
+		}
+
+		created = append(created, path)
+	}
+
+	return
+}
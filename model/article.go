@@ -15,19 +15,22 @@ type ArticleModel interface {
 	GetArticleList(ctx context.Context, where *Where) (articleList []*dto.ArticleWithExtend, err error)
 	FindArticle(ctx context.Context, where *Where) (article *dto.ArticleWithExtend, err error)
 	UpdateArticle(ctx context.Context, article *entities.Article) (result sql.Result, err error)
-	DeleteArticle(ctx context.Context, articleId int64) (result sql.Result, err error)
+	PatchArticle(ctx context.Context, articleId int64, version int64, fields map[string]any, updatedBy int64) (
+		result sql.Result, err error)
+	DeleteArticle(ctx context.Context, articleId int64, version int64) (result sql.Result, err error)
 	GetAvailableCategoryId(ctx context.Context) (articles []*entities.Article, err error)
 }
 
-func NewArticleModel(db *sql.DB) ArticleModel {
-	return &PostgresRepository{db}
+// NewArticleModel reads article lists and articles through reader, which may
+// be nil to use db.
+func NewArticleModel(db *sql.DB, reader DBTX) ArticleModel {
+	return &PostgresRepository{DB: NewTracedDB(db), Reader: reader}
 }
 
 func (postgres *PostgresRepository) CreateArticle(ctx context.Context, article *entities.Article) (
 	result sql.Result, err error) {
 
-	lowerTags := strings.ToLower(*article.Tags)
-	cleanTags := strings.Replace(lowerTags, " ", "", -1)
+	cleanTags := CleanTags(article.Tags)
 
 	queryScript := `
 		INSERT INTO articles (
@@ -56,6 +59,16 @@ func (postgres *PostgresRepository) CreateArticle(ctx context.Context, article *
 	)
 }
 
+// CleanTags lower-cases tags and strips spaces, a nil value stays NULL.
+func CleanTags(tags *string) *string {
+	if tags == nil {
+		return nil
+	}
+
+	cleanTags := strings.Replace(strings.ToLower(*tags), " ", "", -1)
+	return &cleanTags
+}
+
 func (postgres *PostgresRepository) GetArticleList(ctx context.Context, where *Where) (
 	articleWithExtendList []*dto.ArticleWithExtend, err error) {
 
@@ -65,6 +78,8 @@ func (postgres *PostgresRepository) GetArticleList(ctx context.Context, where *W
 		SELECT	a.id
 		    	, a.title
 				, c.name AS category_name
+				, a.category_id
+				, a.user_id
 		FROM 	articles a
 				JOIN users u ON a.user_id = u.id
 				JOIN categories c ON a.category_id = c.id
@@ -72,7 +87,7 @@ func (postgres *PostgresRepository) GetArticleList(ctx context.Context, where *W
 
 	query := fmt.Sprintf("%s %s %s %s", queryScript, where.Parameter, where.Order, where.Limit)
 
-	rows, err := postgres.DB.QueryContext(ctx, query, where.Values...)
+	rows, err := postgres.reader().QueryContext(ctx, query, where.Values...)
 	if err != nil {
 		return
 	}
@@ -93,6 +108,8 @@ func (postgres *PostgresRepository) GetArticleList(ctx context.Context, where *W
 			&articleWithExtend.Id,
 			&articleWithExtend.Title,
 			&articleWithExtend.CategoryName,
+			&articleWithExtend.CategoryId,
+			&articleWithExtend.UserId,
 		)
 
 		if err != nil {
@@ -130,13 +147,14 @@ func (postgres *PostgresRepository) FindArticle(ctx context.Context, where *Wher
 				, a.image
 		     
 				, u.avatar
+				, a.version
 		FROM 	articles a
 				JOIN users u ON a.user_id = u.id
 				JOIN categories c ON a.category_id = c.id
 	`
 
 	query := fmt.Sprintf("%s %s", queryScript, where.Parameter)
-	row := postgres.DB.QueryRowContext(ctx, query, where.Values...)
+	row := postgres.reader().QueryRowContext(ctx, query, where.Values...)
 
 	articleWithExtend = new(dto.ArticleWithExtend)
 	err = row.Scan(
@@ -159,6 +177,7 @@ func (postgres *PostgresRepository) FindArticle(ctx context.Context, where *Wher
 		&articleWithExtend.Image,
 
 		&articleWithExtend.Avatar,
+		&articleWithExtend.Version,
 	)
 
 	if err != nil {
@@ -168,11 +187,12 @@ func (postgres *PostgresRepository) FindArticle(ctx context.Context, where *Wher
 	return
 }
 
+// UpdateArticle only writes when article.Version is still the stored version,
+// a result without affected rows means the article changed or is gone.
 func (postgres *PostgresRepository) UpdateArticle(ctx context.Context, article *entities.Article) (
 	result sql.Result, err error) {
 
-	lowerTags := strings.ToLower(*article.Tags)
-	cleanTags := strings.Replace(lowerTags, " ", "", -1)
+	cleanTags := CleanTags(article.Tags)
 
 	queryScript := `
 		UPDATE 	articles SET 
@@ -185,7 +205,9 @@ func (postgres *PostgresRepository) UpdateArticle(ctx context.Context, article *
 		        , description = $6
 		        , image = $7
 		        , updated_by = $8
-		WHERE 	id = $9
+		        , updated_at = CURRENT_TIMESTAMP
+		        , version = version + 1
+		WHERE 	id = $9 AND version = $10
 		`
 
 	return postgres.DB.ExecContext(ctx, queryScript,
@@ -199,14 +221,40 @@ func (postgres *PostgresRepository) UpdateArticle(ctx context.Context, article *
 		article.Image,
 		article.UpdatedBy,
 		article.Id,
+		article.Version,
 	)
 }
 
-func (postgres *PostgresRepository) DeleteArticle(ctx context.Context, articleId int64) (
+var articlePatchColumns = map[string]bool{
+	"category_id": true,
+	"content":     true,
+	"title":       true,
+	"tags":        true,
+	"description": true,
+	"image":       true,
+}
+
+// PatchArticle updates only the given columns, tags are cleaned like on create.
+func (postgres *PostgresRepository) PatchArticle(ctx context.Context, articleId int64, version int64,
+	fields map[string]any, updatedBy int64) (result sql.Result, err error) {
+
+	if tags, ok := fields["tags"].(*string); ok {
+		fields["tags"] = CleanTags(tags)
+	}
+
+	queryScript, values, err := patchQuery("articles", articlePatchColumns, articleId, version, fields, updatedBy)
+	if err != nil {
+		return
+	}
+
+	return postgres.DB.ExecContext(ctx, queryScript, values...)
+}
+
+func (postgres *PostgresRepository) DeleteArticle(ctx context.Context, articleId int64, version int64) (
 	result sql.Result, err error) {
 
-	queryScript := `DELETE FROM articles WHERE id = $1`
-	return postgres.DB.ExecContext(ctx, queryScript, articleId)
+	queryScript := `DELETE FROM articles WHERE id = $1 AND version = $2`
+	return postgres.DB.ExecContext(ctx, queryScript, articleId, version)
 }
 
 func (postgres *PostgresRepository) GetAvailableCategoryId(ctx context.Context) (
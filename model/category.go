@@ -14,11 +14,14 @@ type CategoryModel interface {
 	GetCategoryList(ctx context.Context, where *Where) (categoryList []*entities.Category, err error)
 	FindCategory(ctx context.Context, where *Where) (category *entities.Category, err error)
 	UpdateCategory(ctx context.Context, category *entities.Category) (result sql.Result, err error)
+	PatchCategory(ctx context.Context, categoryId int64, version int64, fields map[string]any, updatedBy int64) (
+		result sql.Result, err error)
 	DeleteCategory(ctx context.Context, categoryId int64) (result sql.Result, err error)
 }
 
-func NewCategoryModel(db *sql.DB) CategoryModel {
-	return &PostgresRepository{db}
+// NewCategoryModel reads category lists through reader, which may be nil to use db.
+func NewCategoryModel(db *sql.DB, reader DBTX) CategoryModel {
+	return &PostgresRepository{DB: NewTracedDB(db), Reader: reader}
 }
 
 func (postgres *PostgresRepository) CreateCategory(ctx context.Context, category *entities.Category) (
@@ -53,7 +56,7 @@ func (postgres *PostgresRepository) GetCategoryList(ctx context.Context, where *
 	`
 
 	query := fmt.Sprintf("%s %s", queryScript, where.Parameter)
-	rows, err := postgres.DB.QueryContext(ctx, query, where.Values...)
+	rows, err := postgres.reader().QueryContext(ctx, query, where.Values...)
 	if err != nil {
 		return
 	}
@@ -102,6 +105,7 @@ func (postgres *PostgresRepository) FindCategory(ctx context.Context, where *Whe
 		     	, updated_by
 		     
 		    	, updated_at
+				, version
 		FROM 	categories
 	`
 
@@ -117,6 +121,7 @@ func (postgres *PostgresRepository) FindCategory(ctx context.Context, where *Whe
 		&category.UpdatedBy,
 
 		&category.UpdatedAt,
+		&category.Version,
 	)
 
 	if err != nil {
@@ -133,16 +138,39 @@ func (postgres *PostgresRepository) UpdateCategory(ctx context.Context, category
 		UPDATE 	categories SET 
 		    	name = $1
 				, updated_by = $2
-		WHERE 	id = $3
+				, updated_at = CURRENT_TIMESTAMP
+				, version = version + 1
+		WHERE 	id = $3 AND version = $4
 		`
 
 	return postgres.DB.ExecContext(ctx, queryScript,
 		strings.ToLower(category.Name),
 		category.UpdatedBy,
 		category.Id,
+		category.Version,
 	)
 }
 
+var categoryPatchColumns = map[string]bool{
+	"name": true,
+}
+
+// PatchCategory updates only the given columns, the name is lower-cased like on create.
+func (postgres *PostgresRepository) PatchCategory(ctx context.Context, categoryId int64, version int64,
+	fields map[string]any, updatedBy int64) (result sql.Result, err error) {
+
+	if name, ok := fields["name"].(string); ok {
+		fields["name"] = strings.ToLower(name)
+	}
+
+	queryScript, values, err := patchQuery("categories", categoryPatchColumns, categoryId, version, fields, updatedBy)
+	if err != nil {
+		return
+	}
+
+	return postgres.DB.ExecContext(ctx, queryScript, values...)
+}
+
 func (postgres *PostgresRepository) DeleteCategory(ctx context.Context, categoryId int64) (
 	result sql.Result, err error) {
 
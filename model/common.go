@@ -1,6 +1,12 @@
 package model
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 func ValidateWhere(where *Where) (whereNew *Where) {
 	whereNew = where
@@ -17,8 +23,26 @@ func ValidateWhere(where *Where) (whereNew *Where) {
 	return
 }
 
+// DBTX is the subset of *sql.DB used by the repositories.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type PostgresRepository struct {
-	DB *sql.DB
+	DB DBTX
+	// Reader serves the read-heavy queries that tolerate replication lag,
+	// DB is used when it is nil.
+	Reader DBTX
+}
+
+func (postgres *PostgresRepository) reader() DBTX {
+	if postgres.Reader != nil {
+		return postgres.Reader
+	}
+
+	return postgres.DB
 }
 
 const (
@@ -32,3 +56,38 @@ type Where struct {
 	Order     string
 	Limit     string
 }
+
+// patchQuery builds an UPDATE touching only the columns in fields, which must
+// all be listed in patchable. updated_by, updated_at and version are always set
+// and the row is only updated while it still has the given version.
+func patchQuery(table string, patchable map[string]bool, id int64, version int64, fields map[string]any,
+	updatedBy int64) (query string, values []any, err error) {
+
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if !patchable[column] {
+			return "", nil, fmt.Errorf("column %s of %s cannot be patched", column, table)
+		}
+
+		columns = append(columns, column)
+	}
+
+	// a stable column order keeps the statement cacheable
+	sort.Strings(columns)
+
+	assignments := make([]string, 0, len(columns)+2)
+	for _, column := range columns {
+		values = append(values, fields[column])
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(values)))
+	}
+
+	values = append(values, updatedBy)
+	assignments = append(assignments, fmt.Sprintf("updated_by = $%d", len(values)),
+		"updated_at = CURRENT_TIMESTAMP", "version = version + 1")
+
+	values = append(values, id, version)
+	query = fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d AND version = $%d",
+		table, strings.Join(assignments, ", "), len(values)-1, len(values))
+
+	return
+}
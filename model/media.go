@@ -0,0 +1,167 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/michaelwp/goblog/entities"
+	"log"
+)
+
+type MediaModel interface {
+	CreateMedia(ctx context.Context, media *entities.Media) (result sql.Result, err error)
+	GetMediaList(ctx context.Context, where *Where) (mediaList []*entities.Media, err error)
+	FindMedia(ctx context.Context, where *Where) (media *entities.Media, err error)
+	// DeleteMedia deletes the media only while no article or user uses its URL.
+	DeleteMedia(ctx context.Context, mediaId int64) (result sql.Result, err error)
+}
+
+func NewMediaModel(db *sql.DB) MediaModel {
+	return &PostgresRepository{DB: NewTracedDB(db)}
+}
+
+// mediaUsage counts the articles and users referencing media.url.
+const mediaUsage = `
+	(SELECT COUNT(*) FROM articles WHERE articles.image = media.url)
+	+ (SELECT COUNT(*) FROM users WHERE users.avatar = media.url)
+`
+
+func (postgres *PostgresRepository) CreateMedia(ctx context.Context, media *entities.Media) (
+	result sql.Result, err error) {
+
+	queryScript := `
+		INSERT INTO media (
+			owner_id
+			, storage_key
+			, url
+			, file_name
+			, content_type
+
+			, size
+			, checksum
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	return postgres.DB.ExecContext(ctx, queryScript,
+		media.OwnerId,
+		media.StorageKey,
+		media.Url,
+		media.FileName,
+		media.ContentType,
+
+		media.Size,
+		media.Checksum,
+	)
+}
+
+func (postgres *PostgresRepository) GetMediaList(ctx context.Context, where *Where) (
+	mediaList []*entities.Media, err error) {
+
+	where = ValidateWhere(where)
+	queryScript := `
+		SELECT	id
+				, owner_id
+				, storage_key
+				, url
+				, file_name
+
+				, content_type
+				, size
+				, checksum
+				, created_at
+				, ` + mediaUsage + ` AS usage
+		FROM 	media
+	`
+
+	query := fmt.Sprintf("%s %s %s %s", queryScript, where.Parameter, where.Order, where.Limit)
+	rows, err := postgres.DB.QueryContext(ctx, query, where.Values...)
+	if err != nil {
+		return
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Println("error closing get media list rows:", err)
+		}
+	}(rows)
+
+	mediaList = make([]*entities.Media, 0)
+
+	for rows.Next() {
+		media := new(entities.Media)
+
+		err = rows.Scan(
+			&media.Id,
+			&media.OwnerId,
+			&media.StorageKey,
+			&media.Url,
+			&media.FileName,
+
+			&media.ContentType,
+			&media.Size,
+			&media.Checksum,
+			&media.CreatedAt,
+			&media.Usage,
+		)
+
+		if err != nil {
+			return
+		}
+
+		mediaList = append(mediaList, media)
+	}
+
+	return
+}
+
+func (postgres *PostgresRepository) FindMedia(ctx context.Context, where *Where) (
+	media *entities.Media, err error) {
+
+	where = ValidateWhere(where)
+	queryScript := `
+		SELECT	id
+				, owner_id
+				, storage_key
+				, url
+				, file_name
+
+				, content_type
+				, size
+				, checksum
+				, created_at
+				, ` + mediaUsage + ` AS usage
+		FROM 	media
+	`
+
+	query := fmt.Sprintf("%s %s", queryScript, where.Parameter)
+	row := postgres.DB.QueryRowContext(ctx, query, where.Values...)
+
+	media = new(entities.Media)
+	err = row.Scan(
+		&media.Id,
+		&media.OwnerId,
+		&media.StorageKey,
+		&media.Url,
+		&media.FileName,
+
+		&media.ContentType,
+		&media.Size,
+		&media.Checksum,
+		&media.CreatedAt,
+		&media.Usage,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (postgres *PostgresRepository) DeleteMedia(ctx context.Context, mediaId int64) (
+	result sql.Result, err error) {
+
+	queryScript := `DELETE FROM media WHERE id = $1 AND ` + mediaUsage + ` = 0`
+	return postgres.DB.ExecContext(ctx, queryScript, mediaId)
+}
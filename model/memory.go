@@ -0,0 +1,1134 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/michaelwp/goblog/dto"
+	"github.com/michaelwp/goblog/entities"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository implements ArticleModel, UserModel, CategoryModel and MediaModel in
+// memory, so handlers run without PostgreSQL. It keeps the unique and foreign
+// key constraints of the migrations and evaluates the Where clauses the
+// controllers build: column comparisons (= or <>) with placeholders joined by
+// AND, ORDER BY a single column and LIMIT.
+type MemoryRepository struct {
+	// tx runs one transaction at a time.
+	tx         sync.Mutex
+	mu         sync.RWMutex
+	users      map[int64]*entities.User
+	categories map[int64]*entities.Category
+	articles   map[int64]*entities.Article
+	media      map[int64]*entities.Media
+
+	// sequences holds the last id of each table, like BIGSERIAL.
+	sequences map[string]int64
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users:      map[int64]*entities.User{},
+		categories: map[int64]*entities.Category{},
+		articles:   map[int64]*entities.Article{},
+		media:      map[int64]*entities.Media{},
+		sequences:  map[string]int64{},
+	}
+}
+
+// Transaction runs fn with the repository itself as every model. Transactions
+// run one at a time and a failed one is rolled back by restoring a copy of the
+// tables taken before fn, so they are serializable among each other.
+func (m *MemoryRepository) Transaction(ctx context.Context,
+	fn func(ctx context.Context, work *UnitOfWork) error) error {
+
+	m.tx.Lock()
+	defer m.tx.Unlock()
+
+	saved := m.snapshot()
+	err := fn(ctx, &UnitOfWork{ArticleModel: m, UserModel: m, CategoryModel: m})
+	if err != nil {
+		m.restore(saved)
+	}
+
+	return err
+}
+
+func (m *MemoryRepository) snapshot() *MemoryRepository {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return &MemoryRepository{
+		users:      copyRows(m.users),
+		categories: copyRows(m.categories),
+		articles:   copyRows(m.articles),
+		media:      copyRows(m.media),
+		sequences:  maps.Clone(m.sequences),
+	}
+}
+
+func (m *MemoryRepository) restore(saved *MemoryRepository) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users, m.categories, m.articles, m.media = saved.users, saved.categories, saved.articles, saved.media
+	m.sequences = saved.sequences
+}
+
+// copyRows copies the rows too, since updates modify them in place.
+func copyRows[T entities.User | entities.Category | entities.Article | entities.Media](table map[int64]*T) map[int64]*T {
+	copied := make(map[int64]*T, len(table))
+	for id, row := range table {
+		clone := *row
+		copied[id] = &clone
+	}
+
+	return copied
+}
+
+// memoryResult is the sql.Result of a write, LastInsertId is the new row id.
+type memoryResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r memoryResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r memoryResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+func (m *MemoryRepository) CreateUser(_ context.Context, user *entities.User) (result sql.Result, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	email := strings.ToLower(user.Email)
+	if m.userByEmail(email, 0) != nil {
+		return nil, uniqueViolation("users", "email")
+	}
+
+	created := &entities.User{
+		Id:        m.nextId("users"),
+		Name:      strings.ToLower(user.Name),
+		Email:     email,
+		Password:  user.Password,
+		Active:    ACTIVE,
+		Admin:     user.Admin,
+		Avatar:    user.Avatar,
+		Page:      user.Page,
+		CreatedBy: user.CreatedBy,
+		CreatedAt: time.Now(),
+		Version:   1,
+	}
+
+	m.users[created.Id] = created
+	return memoryResult{lastInsertId: created.Id, rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) GetUserList(_ context.Context, where *Where) (userList []*entities.User, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users, err := selectRows(sortedRows(m.users), userColumns, where)
+	if err != nil {
+		return nil, err
+	}
+
+	userList = make([]*entities.User, 0, len(users))
+	for _, user := range users {
+		// the list query does not select the version
+		listed := *user
+		listed.Version = 0
+		userList = append(userList, &listed)
+	}
+
+	return userList, nil
+}
+
+func (m *MemoryRepository) FindUser(_ context.Context, where *Where) (user *entities.User, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users, err := selectRows(sortedRows(m.users), userColumns, where)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	found := *users[0]
+	return &found, nil
+}
+
+func (m *MemoryRepository) UpdateOnlineStatus(_ context.Context, user *entities.User) (result sql.Result, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[user.Id]
+	if !ok {
+		return memoryResult{}, nil
+	}
+
+	stored.Online = user.Online
+	stored.UpdatedBy = user.UpdatedBy
+	touch(&stored.UpdatedAt, &stored.Version)
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) UpdatePassword(_ context.Context, user *entities.User) (result sql.Result, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[user.Id]
+	if !ok {
+		return memoryResult{}, nil
+	}
+
+	stored.Password = user.Password
+	stored.UpdatedBy = user.UpdatedBy
+	touch(&stored.UpdatedAt, &stored.Version)
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) PatchUser(_ context.Context, userId int64, version int64, fields map[string]any,
+	updatedBy int64) (result sql.Result, err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = checkPatchable("users", userPatchColumns, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, ok := m.users[userId]
+	if !ok || stored.Version != version {
+		return memoryResult{}, nil
+	}
+
+	patched := *stored
+	for column, value := range fields {
+		switch column {
+		case "name":
+			patched.Name, err = asString(column, value)
+			patched.Name = strings.ToLower(patched.Name)
+		case "email":
+			patched.Email, err = asString(column, value)
+			patched.Email = strings.ToLower(patched.Email)
+		case "password":
+			patched.Password, err = asString(column, value)
+		case "avatar":
+			patched.Avatar, err = asNullString(column, value)
+		case "page":
+			patched.Page, err = asNullString(column, value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if m.userByEmail(patched.Email, userId) != nil {
+		return nil, uniqueViolation("users", "email")
+	}
+
+	patched.UpdatedBy = &updatedBy
+	touch(&patched.UpdatedAt, &patched.Version)
+	m.users[userId] = &patched
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) DeleteUser(_ context.Context, userId int64) (result sql.Result, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userId]; !ok {
+		return memoryResult{}, nil
+	}
+
+	for _, article := range m.articles {
+		if article.UserId == userId || article.CreatedBy == userId {
+			return nil, foreignKeyViolation("articles", "users")
+		}
+	}
+
+	for _, category := range m.categories {
+		if category.CreatedBy == userId {
+			return nil, foreignKeyViolation("categories", "users")
+		}
+	}
+
+	for _, media := range m.media {
+		if media.OwnerId == userId {
+			return nil, foreignKeyViolation("media", "users")
+		}
+	}
+
+	delete(m.users, userId)
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) CreateCategory(_ context.Context, category *entities.Category) (
+	result sql.Result, err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := strings.ToLower(category.Name)
+	if m.categoryByName(name, 0) != nil {
+		return nil, uniqueViolation("categories", "name")
+	}
+
+	if _, ok := m.users[category.CreatedBy]; !ok {
+		return nil, foreignKeyViolation("categories", "users")
+	}
+
+	created := &entities.Category{
+		Id:        m.nextId("categories"),
+		Name:      name,
+		CreatedBy: category.CreatedBy,
+		CreatedAt: time.Now(),
+		Version:   1,
+	}
+
+	m.categories[created.Id] = created
+	return memoryResult{lastInsertId: created.Id, rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) GetCategoryList(_ context.Context, where *Where) (
+	categoryList []*entities.Category, err error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	categories, err := selectRows(sortedRows(m.categories), categoryColumns, where)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryList = make([]*entities.Category, 0, len(categories))
+	for _, category := range categories {
+		// the list query does not select the version
+		listed := *category
+		listed.Version = 0
+		categoryList = append(categoryList, &listed)
+	}
+
+	return categoryList, nil
+}
+
+func (m *MemoryRepository) FindCategory(_ context.Context, where *Where) (category *entities.Category, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	categories, err := selectRows(sortedRows(m.categories), categoryColumns, where)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(categories) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	found := *categories[0]
+	return &found, nil
+}
+
+func (m *MemoryRepository) UpdateCategory(_ context.Context, category *entities.Category) (
+	result sql.Result, err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.categories[category.Id]
+	if !ok || stored.Version != category.Version {
+		return memoryResult{}, nil
+	}
+
+	name := strings.ToLower(category.Name)
+	if m.categoryByName(name, category.Id) != nil {
+		return nil, uniqueViolation("categories", "name")
+	}
+
+	stored.Name = name
+	stored.UpdatedBy = category.UpdatedBy
+	touch(&stored.UpdatedAt, &stored.Version)
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) PatchCategory(_ context.Context, categoryId int64, version int64, fields map[string]any,
+	updatedBy int64) (result sql.Result, err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = checkPatchable("categories", categoryPatchColumns, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, ok := m.categories[categoryId]
+	if !ok || stored.Version != version {
+		return memoryResult{}, nil
+	}
+
+	patched := *stored
+	if value, ok := fields["name"]; ok {
+		name, err := asString("name", value)
+		if err != nil {
+			return nil, err
+		}
+
+		patched.Name = strings.ToLower(name)
+	}
+
+	if m.categoryByName(patched.Name, categoryId) != nil {
+		return nil, uniqueViolation("categories", "name")
+	}
+
+	patched.UpdatedBy = &updatedBy
+	touch(&patched.UpdatedAt, &patched.Version)
+	m.categories[categoryId] = &patched
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) DeleteCategory(_ context.Context, categoryId int64) (result sql.Result, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.categories[categoryId]; !ok {
+		return memoryResult{}, nil
+	}
+
+	for _, article := range m.articles {
+		if article.CategoryId == categoryId {
+			return nil, foreignKeyViolation("articles", "categories")
+		}
+	}
+
+	delete(m.categories, categoryId)
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) CreateArticle(_ context.Context, article *entities.Article) (
+	result sql.Result, err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = m.checkArticleReferences(article)
+	if err != nil {
+		return nil, err
+	}
+
+	created := &entities.Article{
+		Id:          m.nextId("articles"),
+		UserId:      article.UserId,
+		CategoryId:  article.CategoryId,
+		Content:     article.Content,
+		Title:       article.Title,
+		Tags:        CleanTags(article.Tags),
+		Description: article.Description,
+		Image:       article.Image,
+		CreatedBy:   article.CreatedBy,
+		CreatedAt:   time.Now(),
+		Version:     1,
+	}
+
+	m.articles[created.Id] = created
+	return memoryResult{lastInsertId: created.Id, rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) GetArticleList(_ context.Context, where *Where) (
+	articleWithExtendList []*dto.ArticleWithExtend, err error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	articles, err := selectRows(m.joinArticles(), articleColumns, where)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the columns of the list query
+	articleWithExtendList = make([]*dto.ArticleWithExtend, 0, len(articles))
+	for _, article := range articles {
+		listed := new(dto.ArticleWithExtend)
+		listed.Id = article.Id
+		listed.Title = article.Title
+		listed.CategoryName = article.CategoryName
+		listed.CategoryId = article.CategoryId
+		listed.UserId = article.UserId
+
+		articleWithExtendList = append(articleWithExtendList, listed)
+	}
+
+	return articleWithExtendList, nil
+}
+
+func (m *MemoryRepository) FindArticle(_ context.Context, where *Where) (
+	articleWithExtend *dto.ArticleWithExtend, err error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	articles, err := selectRows(m.joinArticles(), articleColumns, where)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(articles) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return articles[0], nil
+}
+
+func (m *MemoryRepository) UpdateArticle(_ context.Context, article *entities.Article) (
+	result sql.Result, err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.articles[article.Id]
+	if !ok || stored.Version != article.Version {
+		return memoryResult{}, nil
+	}
+
+	err = m.checkArticleReferences(article)
+	if err != nil {
+		return nil, err
+	}
+
+	stored.UserId = article.UserId
+	stored.CategoryId = article.CategoryId
+	stored.Content = article.Content
+	stored.Title = article.Title
+	stored.Tags = CleanTags(article.Tags)
+	stored.Description = article.Description
+	stored.Image = article.Image
+	stored.UpdatedBy = article.UpdatedBy
+	touch(&stored.UpdatedAt, &stored.Version)
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) PatchArticle(_ context.Context, articleId int64, version int64, fields map[string]any,
+	updatedBy int64) (result sql.Result, err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = checkPatchable("articles", articlePatchColumns, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, ok := m.articles[articleId]
+	if !ok || stored.Version != version {
+		return memoryResult{}, nil
+	}
+
+	patched := *stored
+	for column, value := range fields {
+		switch column {
+		case "category_id":
+			patched.CategoryId, err = asInt64(column, value)
+		case "content":
+			patched.Content, err = asString(column, value)
+		case "title":
+			patched.Title, err = asString(column, value)
+		case "tags":
+			patched.Tags, err = asNullString(column, value)
+			patched.Tags = CleanTags(patched.Tags)
+		case "description":
+			patched.Description, err = asNullString(column, value)
+		case "image":
+			patched.Image, err = asNullString(column, value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	patched.UpdatedBy = &updatedBy
+	err = m.checkArticleReferences(&patched)
+	if err != nil {
+		return nil, err
+	}
+
+	touch(&patched.UpdatedAt, &patched.Version)
+	m.articles[articleId] = &patched
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) DeleteArticle(_ context.Context, articleId int64, version int64) (
+	result sql.Result, err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.articles[articleId]
+	if !ok || stored.Version != version {
+		return memoryResult{}, nil
+	}
+
+	delete(m.articles, articleId)
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) GetAvailableCategoryId(_ context.Context) (articles []*entities.Article, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := map[int64]bool{}
+	articles = make([]*entities.Article, 0)
+	for _, article := range sortedRows(m.articles) {
+		if !seen[article.CategoryId] {
+			seen[article.CategoryId] = true
+			articles = append(articles, &entities.Article{CategoryId: article.CategoryId})
+		}
+	}
+
+	return articles, nil
+}
+
+// joinArticles mirrors articles JOIN users JOIN categories, ordered by id.
+func (m *MemoryRepository) CreateMedia(_ context.Context, media *entities.Media) (result sql.Result, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.media {
+		if existing.StorageKey == media.StorageKey {
+			return nil, uniqueViolation("media", "storage_key")
+		}
+
+		if existing.OwnerId == media.OwnerId && existing.Checksum == media.Checksum {
+			return nil, uniqueViolation("media", "owner_id_checksum")
+		}
+	}
+
+	if _, ok := m.users[media.OwnerId]; !ok {
+		return nil, foreignKeyViolation("media", "users")
+	}
+
+	created := *media
+	created.Id = m.nextId("media")
+	created.Usage = 0
+	created.CreatedAt = time.Now()
+
+	m.media[created.Id] = &created
+	return memoryResult{lastInsertId: created.Id, rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepository) GetMediaList(_ context.Context, where *Where) (mediaList []*entities.Media, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.selectMedia(where)
+}
+
+func (m *MemoryRepository) FindMedia(_ context.Context, where *Where) (media *entities.Media, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mediaList, err := m.selectMedia(where)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mediaList) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return mediaList[0], nil
+}
+
+func (m *MemoryRepository) DeleteMedia(_ context.Context, mediaId int64) (result sql.Result, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	media, ok := m.media[mediaId]
+	if !ok || m.mediaUsage(media.Url) > 0 {
+		return memoryResult{}, nil
+	}
+
+	delete(m.media, mediaId)
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+// selectMedia returns copies of the selected media with their usage, it must
+// be called with mu held.
+func (m *MemoryRepository) selectMedia(where *Where) ([]*entities.Media, error) {
+	selected, err := selectRows(sortedRows(m.media), mediaColumns, where)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaList := make([]*entities.Media, 0, len(selected))
+	for _, media := range selected {
+		listed := *media
+		listed.Usage = m.mediaUsage(media.Url)
+		mediaList = append(mediaList, &listed)
+	}
+
+	return mediaList, nil
+}
+
+// mediaUsage counts the articles and users referencing url.
+func (m *MemoryRepository) mediaUsage(url string) (usage int64) {
+	for _, article := range m.articles {
+		if article.Image != nil && *article.Image == url {
+			usage++
+		}
+	}
+
+	for _, user := range m.users {
+		if user.Avatar != nil && *user.Avatar == url {
+			usage++
+		}
+	}
+
+	return
+}
+
+func (m *MemoryRepository) joinArticles() []*dto.ArticleWithExtend {
+	joined := make([]*dto.ArticleWithExtend, 0, len(m.articles))
+	for _, article := range sortedRows(m.articles) {
+		user, userOk := m.users[article.UserId]
+		category, categoryOk := m.categories[article.CategoryId]
+		if !userOk || !categoryOk {
+			continue
+		}
+
+		joined = append(joined, &dto.ArticleWithExtend{
+			Article: *article,
+			ArticleExtend: dto.ArticleExtend{
+				UserName:     user.Name,
+				CategoryName: category.Name,
+				Page:         user.Page,
+				Avatar:       user.Avatar,
+			},
+		})
+	}
+
+	return joined
+}
+
+func (m *MemoryRepository) checkArticleReferences(article *entities.Article) error {
+	if _, ok := m.categories[article.CategoryId]; !ok {
+		return foreignKeyViolation("articles", "categories")
+	}
+
+	userIds := []int64{article.UserId}
+	if article.CreatedBy != 0 {
+		userIds = append(userIds, article.CreatedBy)
+	}
+
+	if article.UpdatedBy != nil {
+		userIds = append(userIds, *article.UpdatedBy)
+	}
+
+	for _, userId := range userIds {
+		if _, ok := m.users[userId]; !ok {
+			return foreignKeyViolation("articles", "users")
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryRepository) userByEmail(email string, exceptId int64) *entities.User {
+	for _, user := range m.users {
+		if user.Email == email && user.Id != exceptId {
+			return user
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryRepository) categoryByName(name string, exceptId int64) *entities.Category {
+	for _, category := range m.categories {
+		if category.Name == name && category.Id != exceptId {
+			return category
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryRepository) nextId(table string) int64 {
+	m.sequences[table]++
+	return m.sequences[table]
+}
+
+// touch sets updated_at to now and increments version, like every UPDATE.
+func touch(updatedAt **time.Time, version *int64) {
+	now := time.Now()
+	*updatedAt = &now
+	*version++
+}
+
+func uniqueViolation(table, column string) error {
+	return fmt.Errorf("%w: duplicate key value violates unique constraint \"%s_%s_key\"", ErrUniqueViolation,
+		table, column)
+}
+
+func foreignKeyViolation(table, referenced string) error {
+	return fmt.Errorf("insert, update or delete on table %q violates foreign key constraint referencing %q",
+		table, referenced)
+}
+
+func checkPatchable(table string, patchable map[string]bool, fields map[string]any) error {
+	for column := range fields {
+		if !patchable[column] {
+			return fmt.Errorf("column %s of %s cannot be patched", column, table)
+		}
+	}
+
+	return nil
+}
+
+func asString(column string, value any) (string, error) {
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+
+	return "", fmt.Errorf("column %s: expected a string, got %T", column, value)
+}
+
+func asNullString(column string, value any) (*string, error) {
+	switch text := value.(type) {
+	case nil:
+		return nil, nil
+	case *string:
+		return text, nil
+	case string:
+		return &text, nil
+	}
+
+	return nil, fmt.Errorf("column %s: expected a string, got %T", column, value)
+}
+
+func asInt64(column string, value any) (int64, error) {
+	if number, ok := normalize(value).(int64); ok {
+		return number, nil
+	}
+
+	return 0, fmt.Errorf("column %s: expected an integer, got %T", column, value)
+}
+
+type row interface {
+	*entities.User | *entities.Category | *entities.Article | *dto.ArticleWithExtend | *entities.Media
+}
+
+// sortedRows returns the rows of a table ordered by id.
+func sortedRows[T *entities.User | *entities.Category | *entities.Article | *entities.Media](table map[int64]T) []T {
+	ids := make([]int64, 0, len(table))
+	for id := range table {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	rows := make([]T, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, table[id])
+	}
+
+	return rows
+}
+
+// column returns the value of a column of a row and whether the column exists.
+type column func(name string) (value any, ok bool)
+
+func userColumns(user *entities.User) column {
+	return func(name string) (any, bool) {
+		switch name {
+		case "id":
+			return user.Id, true
+		case "name":
+			return user.Name, true
+		case "email":
+			return user.Email, true
+		case "online":
+			return user.Online, true
+		case "active":
+			return user.Active, true
+		case "admin":
+			return user.Admin, true
+		case "avatar":
+			return user.Avatar, true
+		case "page":
+			return user.Page, true
+		case "created_by":
+			return user.CreatedBy, true
+		case "created_at":
+			return user.CreatedAt, true
+		case "version":
+			return user.Version, true
+		}
+
+		return nil, false
+	}
+}
+
+func categoryColumns(category *entities.Category) column {
+	return func(name string) (any, bool) {
+		switch name {
+		case "id":
+			return category.Id, true
+		case "name":
+			return category.Name, true
+		case "created_by":
+			return category.CreatedBy, true
+		case "created_at":
+			return category.CreatedAt, true
+		case "version":
+			return category.Version, true
+		}
+
+		return nil, false
+	}
+}
+
+func mediaColumns(media *entities.Media) column {
+	return func(name string) (any, bool) {
+		switch name {
+		case "id":
+			return media.Id, true
+		case "owner_id":
+			return media.OwnerId, true
+		case "storage_key":
+			return media.StorageKey, true
+		case "url":
+			return media.Url, true
+		case "checksum":
+			return media.Checksum, true
+		case "created_at":
+			return media.CreatedAt, true
+		}
+
+		return nil, false
+	}
+}
+
+// articleColumns resolves the aliases of the article queries: a. for
+// articles, u. for users and c. for categories.
+func articleColumns(article *dto.ArticleWithExtend) column {
+	return func(name string) (any, bool) {
+		switch name {
+		case "u.name":
+			return article.UserName, true
+		case "u.page":
+			return article.Page, true
+		case "u.avatar":
+			return article.Avatar, true
+		case "c.name":
+			return article.CategoryName, true
+		}
+
+		switch strings.TrimPrefix(name, "a.") {
+		case "id":
+			return article.Id, true
+		case "user_id":
+			return article.UserId, true
+		case "category_id":
+			return article.CategoryId, true
+		case "title":
+			return article.Title, true
+		case "content":
+			return article.Content, true
+		case "tags":
+			return article.Tags, true
+		case "created_by":
+			return article.CreatedBy, true
+		case "created_at":
+			return article.CreatedAt, true
+		case "version":
+			return article.Version, true
+		}
+
+		return nil, false
+	}
+}
+
+type condition struct {
+	column string
+	equal  bool
+	value  any
+}
+
+// selectRows filters, orders and limits rows as the SQL of where would.
+func selectRows[T row](rows []T, columnsOf func(T) column, where *Where) ([]T, error) {
+	where = ValidateWhere(where)
+	clause := strings.Join([]string{where.Parameter, where.Order, where.Limit}, " ")
+	clause = strings.NewReplacer("!=", " <> ", "<>", " <> ", "=", " = ").Replace(clause)
+	tokens := strings.Fields(clause)
+
+	var conditions []condition
+	orderBy, descending, limit := "", false, -1
+
+	next := func() string {
+		if len(tokens) == 0 {
+			return ""
+		}
+
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token
+	}
+
+	for len(tokens) > 0 {
+		switch keyword := strings.ToUpper(next()); keyword {
+		case "WHERE", "AND":
+			name, operator, placeholder := next(), next(), next()
+			if operator != "=" && operator != "<>" {
+				return nil, fmt.Errorf("memory repository: unsupported operator %q", operator)
+			}
+
+			index, err := strconv.Atoi(strings.TrimPrefix(placeholder, "$"))
+			if err != nil || !strings.HasPrefix(placeholder, "$") || index < 1 || index > len(where.Values) {
+				return nil, fmt.Errorf("memory repository: unsupported operand %q", placeholder)
+			}
+
+			conditions = append(conditions, condition{
+				column: name,
+				equal:  operator == "=",
+				value:  where.Values[index-1],
+			})
+		case "ORDER":
+			if strings.ToUpper(next()) != "BY" {
+				return nil, fmt.Errorf("memory repository: unsupported clause %q", where.Order)
+			}
+
+			orderBy = next()
+			if len(tokens) > 0 && (strings.ToUpper(tokens[0]) == "ASC" || strings.ToUpper(tokens[0]) == "DESC") {
+				descending = strings.ToUpper(next()) == "DESC"
+			}
+		case "LIMIT":
+			var err error
+			limit, err = strconv.Atoi(next())
+			if err != nil {
+				return nil, fmt.Errorf("memory repository: unsupported limit: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("memory repository: unsupported keyword %q", keyword)
+		}
+	}
+
+	selected := make([]T, 0, len(rows))
+	for _, candidate := range rows {
+		columns := columnsOf(candidate)
+
+		matches := true
+		for _, condition := range conditions {
+			value, ok := columns(condition.column)
+			if !ok {
+				return nil, fmt.Errorf("memory repository: unknown column %q", condition.column)
+			}
+
+			// NULL compares to nothing, in either direction
+			left, right := normalize(value), normalize(condition.value)
+			if left == nil || right == nil || (left == right) != condition.equal {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			selected = append(selected, candidate)
+		}
+	}
+
+	if orderBy != "" {
+		if len(selected) > 0 {
+			if _, ok := columnsOf(selected[0])(orderBy); !ok {
+				return nil, fmt.Errorf("memory repository: unknown column %q", orderBy)
+			}
+		}
+
+		sort.SliceStable(selected, func(i, j int) bool {
+			left, _ := columnsOf(selected[i])(orderBy)
+			right, _ := columnsOf(selected[j])(orderBy)
+			if descending {
+				return less(right, left)
+			}
+
+			return less(left, right)
+		})
+	}
+
+	if limit >= 0 && len(selected) > limit {
+		selected = selected[:limit]
+	}
+
+	return selected, nil
+}
+
+// normalize converts a column or placeholder value so equal SQL values
+// compare equal in Go: integers become int64, Status a bool and pointers their
+// value, or nil for NULL.
+func normalize(value any) any {
+	switch typed := value.(type) {
+	case int:
+		return int64(typed)
+	case int32:
+		return int64(typed)
+	case uint64:
+		return int64(typed)
+	case entities.Status:
+		return bool(typed)
+	case *string:
+		if typed == nil {
+			return nil
+		}
+
+		return *typed
+	case *int64:
+		if typed == nil {
+			return nil
+		}
+
+		return *typed
+	}
+
+	return value
+}
+
+func less(left, right any) bool {
+	switch typed := normalize(left).(type) {
+	case int64:
+		other, _ := normalize(right).(int64)
+		return typed < other
+	case string:
+		other, _ := normalize(right).(string)
+		return typed < other
+	case time.Time:
+		other, _ := normalize(right).(time.Time)
+		return typed.Before(other)
+	case bool:
+		other, _ := normalize(right).(bool)
+		return !typed && other
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/michaelwp/goblog/tool"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// READ_PRIMARY is the context key marking a request whose reads must see its
+// user's latest writes, a true value routes them to the primary.
+const READ_PRIMARY = "read_primary"
+
+// ReadPrimary marks ctx with READ_PRIMARY, for reads whose result outlives
+// the request, e.g. cache fills, which must not copy a lagging replica.
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, READ_PRIMARY, true)
+}
+
+// ReplicaRouter is a DBTX sending queries to the healthy replicas round-robin
+// and statements to the primary. Queries go to the primary too when no replica
+// is healthy or the context is marked with READ_PRIMARY.
+type ReplicaRouter struct {
+	primary  DBTX
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	traced  DBTX
+	healthy atomic.Bool
+}
+
+// NewReplicaRouter returns a router whose replicas stay unused until Monitor
+// has seen them answer.
+func NewReplicaRouter(primary *sql.DB, replicas []*sql.DB) *ReplicaRouter {
+	router := &ReplicaRouter{primary: NewTracedDB(primary)}
+	for i, db := range replicas {
+		router.replicas = append(router.replicas, &replica{
+			name:   "replica " + strconv.Itoa(i),
+			db:     db,
+			traced: NewTracedDB(db),
+		})
+	}
+
+	return router
+}
+
+func (r *ReplicaRouter) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+func (r *ReplicaRouter) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.reader(ctx).QueryContext(ctx, query, args...)
+}
+
+func (r *ReplicaRouter) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.reader(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// Monitor pings every replica each interval until ctx is cancelled.
+func (r *ReplicaRouter) Monitor(ctx context.Context, interval time.Duration) {
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, replica := range r.replicas {
+			r.check(ctx, replica, interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the replicas, the primary is owned by the caller.
+func (r *ReplicaRouter) Close() error {
+	errs := make([]error, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		errs = append(errs, replica.db.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *ReplicaRouter) check(ctx context.Context, replica *replica, timeout time.Duration) {
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := replica.db.PingContext(pingCtx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	// log state changes only, not every failed ping
+	if replica.healthy.Swap(err == nil) != (err == nil) && err != nil {
+		tool.PrintLog("postgres "+replica.name+" unhealthy", err)
+	}
+}
+
+func (r *ReplicaRouter) reader(ctx context.Context) DBTX {
+	if primary, _ := ctx.Value(READ_PRIMARY).(bool); primary {
+		return r.primary
+	}
+
+	start := r.next.Add(1)
+	for i := range uint64(len(r.replicas)) {
+		replica := r.replicas[(start+i)%uint64(len(r.replicas))]
+		if replica.healthy.Load() {
+			return replica.traced
+		}
+	}
+
+	return r.primary
+}
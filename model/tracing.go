@@ -0,0 +1,103 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/michaelwp/goblog/tracing"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"regexp"
+	"strings"
+)
+
+var (
+	sqlWhitespace = regexp.MustCompile(`\s+`)
+	sqlLiteral    = regexp.MustCompile(`\$\d+|'(?:[^']|'')*'|\b\d+(?:\.\d+)?\b`)
+)
+
+// tracedDB wraps a DBTX and records an OpenTelemetry span for every query.
+type tracedDB struct {
+	db DBTX
+}
+
+func NewTracedDB(db DBTX) DBTX {
+	return &tracedDB{db}
+}
+
+func (t *tracedDB) ExecContext(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	result, err = t.db.ExecContext(ctx, query, args...)
+	recordQueryError(span, err)
+
+	return
+}
+
+// QueryContext ends its span once the query answered, before the caller reads
+// the rows: DBTX returns the concrete *sql.Rows, which cannot be wrapped to end
+// it on Close. The span therefore misses the time spent reading the rows and
+// errors only reported by rows.Err().
+func (t *tracedDB) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	rows, err = t.db.QueryContext(ctx, query, args...)
+	recordQueryError(span, err)
+
+	return
+}
+
+func (t *tracedDB) QueryRowContext(ctx context.Context, query string, args ...any) (row *sql.Row) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	row = t.db.QueryRowContext(ctx, query, args...)
+	recordQueryError(span, row.Err())
+
+	return
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	statement := SanitizeSQL(query)
+
+	operation := statement
+	if i := strings.IndexByte(statement, ' '); i > 0 {
+		operation = statement[:i]
+	}
+
+	return tracing.Tracer("github.com/michaelwp/goblog/model").Start(ctx, "postgres "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(statement),
+		),
+	)
+}
+
+func recordQueryError(span trace.Span, err error) {
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// SanitizeSQL collapses whitespace and replaces string and numeric literals
+// with "?" so the statement can be attached to a span without leaking data.
+// Positional placeholders ($1, $2, ...) are kept as they are.
+func SanitizeSQL(query string) string {
+	query = strings.TrimSpace(sqlWhitespace.ReplaceAllString(query, " "))
+
+	return sqlLiteral.ReplaceAllStringFunc(query, func(literal string) string {
+		if strings.HasPrefix(literal, "$") {
+			return literal
+		}
+
+		return "?"
+	})
+}
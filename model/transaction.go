@@ -0,0 +1,106 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	// TRANSACTION_ATTEMPTS bounds how often a transaction is run when
+	// PostgreSQL aborts it to keep the serializable isolation.
+	TRANSACTION_ATTEMPTS    = 3
+	TRANSACTION_RETRY_DELAY = 20 * time.Millisecond
+
+	PG_UNIQUE_VIOLATION      = "23505"
+	PG_SERIALIZATION_FAILURE = "40001"
+	PG_DEADLOCK_DETECTED     = "40P01"
+)
+
+// ErrUniqueViolation is wrapped by the in-memory repository when a row would
+// duplicate a unique column, PostgreSQL reports it as PG_UNIQUE_VIOLATION.
+var ErrUniqueViolation = errors.New("unique violation")
+
+// UnitOfWork holds repositories sharing one transaction.
+type UnitOfWork struct {
+	ArticleModel  ArticleModel
+	UserModel     UserModel
+	CategoryModel CategoryModel
+}
+
+// Transactor runs fn in a transaction, committed when fn returns nil and
+// rolled back otherwise. fn may run again after a serialization failure, so
+// it must not have side effects outside the transaction.
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context, work *UnitOfWork) error) error
+}
+
+type postgresTransactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) Transactor {
+	return &postgresTransactor{db}
+}
+
+// Transaction uses the serializable isolation level, so a check-then-insert
+// racing another one fails with a serialization failure or a unique
+// violation instead of writing a duplicate.
+func (p *postgresTransactor) Transaction(ctx context.Context, fn func(ctx context.Context, work *UnitOfWork) error) (
+	err error) {
+
+	for attempt := 1; ; attempt++ {
+		err = p.transaction(ctx, fn)
+		if err == nil || !isRetryable(err) || attempt == TRANSACTION_ATTEMPTS {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * TRANSACTION_RETRY_DELAY):
+		}
+	}
+}
+
+func (p *postgresTransactor) transaction(ctx context.Context, fn func(ctx context.Context, work *UnitOfWork) error) (
+	err error) {
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+
+	// a no-op once committed, and also releases the connection on a panic
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	repository := &PostgresRepository{DB: NewTracedDB(tx)}
+	err = fn(ctx, &UnitOfWork{
+		ArticleModel:  repository,
+		UserModel:     repository,
+		CategoryModel: repository,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsUniqueViolation reports whether err, possibly wrapped, is a unique constraint violation.
+func IsUniqueViolation(err error) bool {
+	return errors.Is(err, ErrUniqueViolation) || hasCode(err, PG_UNIQUE_VIOLATION)
+}
+
+func isRetryable(err error) bool {
+	return hasCode(err, PG_SERIALIZATION_FAILURE) || hasCode(err, PG_DEADLOCK_DETECTED)
+}
+
+func hasCode(err error, code pq.ErrorCode) bool {
+	var pqError *pq.Error
+	return errors.As(err, &pqError) && pqError.Code == code
+}
@@ -0,0 +1,229 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	unique := &pq.Error{Code: PG_UNIQUE_VIOLATION, Constraint: "users_email_key"}
+
+	for name, test := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"pq error":             {unique, true},
+		"wrapped pq error":     {fmt.Errorf("insert user: %w", unique), true},
+		"twice wrapped":        {fmt.Errorf("create: %w", fmt.Errorf("insert user: %w", unique)), true},
+		"joined":               {errors.Join(errors.New("rollback"), unique), true},
+		"in-memory":            {uniqueViolation("users", "email"), true},
+		"serialization":        {&pq.Error{Code: PG_SERIALIZATION_FAILURE}, false},
+		"foreign key":          {&pq.Error{Code: "23503"}, false},
+		"no rows":              {sql.ErrNoRows, false},
+		"message without code": {errors.New("duplicate key value violates unique constraint"), false},
+		"nil":                  {nil, false},
+	} {
+		if got := IsUniqueViolation(test.err); got != test.want {
+			t.Errorf("%s: IsUniqueViolation(%v) = %v, want %v", name, test.err, got, test.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for name, test := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"serialization failure": {&pq.Error{Code: PG_SERIALIZATION_FAILURE}, true},
+		"deadlock":              {&pq.Error{Code: PG_DEADLOCK_DETECTED}, true},
+		"wrapped":               {fmt.Errorf("commit: %w", &pq.Error{Code: PG_SERIALIZATION_FAILURE}), true},
+		"unique violation":      {&pq.Error{Code: PG_UNIQUE_VIOLATION}, false},
+		"other":                 {errors.New("connection reset"), false},
+		"nil":                   {nil, false},
+	} {
+		if got := isRetryable(test.err); got != test.want {
+			t.Errorf("%s: isRetryable(%v) = %v, want %v", name, test.err, got, test.want)
+		}
+	}
+}
+
+// stubDriver opens connections whose transactions fail to commit with the
+// errors in commitErrors, in order, then succeed.
+type stubDriver struct {
+	mu           sync.Mutex
+	begins       int
+	commits      int
+	rollbacks    int
+	isolation    driver.IsolationLevel
+	commitErrors []error
+}
+
+type stubConn struct {
+	driver *stubDriver
+}
+
+type stubTx struct {
+	driver *stubDriver
+}
+
+var (
+	stubDriversMu sync.Mutex
+	stubDrivers   = 0
+)
+
+func openStub(t *testing.T, commitErrors ...error) (*sql.DB, *stubDriver) {
+	t.Helper()
+
+	stub := &stubDriver{commitErrors: commitErrors}
+
+	stubDriversMu.Lock()
+	stubDrivers++
+	name := fmt.Sprintf("transaction_stub_%d", stubDrivers)
+	stubDriversMu.Unlock()
+
+	sql.Register(name, stub)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db, stub
+}
+
+func (d *stubDriver) Open(string) (driver.Conn, error) {
+	return &stubConn{d}, nil
+}
+
+func (c *stubConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("stub: no statements")
+}
+
+func (c *stubConn) Close() error {
+	return nil
+}
+
+func (c *stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("stub: BeginTx only")
+}
+
+func (c *stubConn) BeginTx(_ context.Context, options driver.TxOptions) (driver.Tx, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+
+	c.driver.begins++
+	c.driver.isolation = options.Isolation
+	return &stubTx{c.driver}, nil
+}
+
+func (tx *stubTx) Commit() error {
+	tx.driver.mu.Lock()
+	defer tx.driver.mu.Unlock()
+
+	tx.driver.commits++
+	if len(tx.driver.commitErrors) == 0 {
+		return nil
+	}
+
+	err := tx.driver.commitErrors[0]
+	tx.driver.commitErrors = tx.driver.commitErrors[1:]
+	return err
+}
+
+func (tx *stubTx) Rollback() error {
+	tx.driver.mu.Lock()
+	defer tx.driver.mu.Unlock()
+
+	tx.driver.rollbacks++
+	return nil
+}
+
+func TestTransactionRetriesSerializationFailures(t *testing.T) {
+	serialization := &pq.Error{Code: PG_SERIALIZATION_FAILURE}
+	db, stub := openStub(t, serialization, &pq.Error{Code: PG_DEADLOCK_DETECTED})
+
+	runs := 0
+	err := NewTransactor(db).Transaction(context.Background(), func(ctx context.Context, work *UnitOfWork) error {
+		runs++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction = %v", err)
+	}
+
+	if runs != 3 || stub.begins != 3 || stub.commits != 3 {
+		t.Fatalf("runs = %d, begins = %d, commits = %d, want 3", runs, stub.begins, stub.commits)
+	}
+
+	if sql.IsolationLevel(stub.isolation) != sql.LevelSerializable {
+		t.Fatalf("isolation = %v, want serializable", sql.IsolationLevel(stub.isolation))
+	}
+}
+
+func TestTransactionStopsAfterAttempts(t *testing.T) {
+	failures := make([]error, TRANSACTION_ATTEMPTS+1)
+	for i := range failures {
+		failures[i] = &pq.Error{Code: PG_SERIALIZATION_FAILURE}
+	}
+
+	db, stub := openStub(t, failures...)
+
+	err := NewTransactor(db).Transaction(context.Background(), func(ctx context.Context, work *UnitOfWork) error {
+		return nil
+	})
+	if !isRetryable(err) {
+		t.Fatalf("Transaction = %v, want the serialization failure", err)
+	}
+
+	if stub.begins != TRANSACTION_ATTEMPTS {
+		t.Fatalf("begins = %d, want %d", stub.begins, TRANSACTION_ATTEMPTS)
+	}
+}
+
+func TestTransactionRetriesFailuresOfFn(t *testing.T) {
+	db, stub := openStub(t)
+
+	runs := 0
+	err := NewTransactor(db).Transaction(context.Background(), func(ctx context.Context, work *UnitOfWork) error {
+		runs++
+		if runs == 1 {
+			return fmt.Errorf("find user: %w", &pq.Error{Code: PG_SERIALIZATION_FAILURE})
+		}
+
+		return nil
+	})
+	if err != nil || runs != 2 {
+		t.Fatalf("Transaction = %v after %d runs", err, runs)
+	}
+
+	// the failed attempt is rolled back, the other one committed
+	if stub.commits != 1 || stub.rollbacks != 1 {
+		t.Fatalf("commits = %d, rollbacks = %d", stub.commits, stub.rollbacks)
+	}
+}
+
+func TestTransactionDoesNotRetryUniqueViolations(t *testing.T) {
+	db, stub := openStub(t)
+
+	unique := &pq.Error{Code: PG_UNIQUE_VIOLATION, Constraint: "users_email_key"}
+	err := NewTransactor(db).Transaction(context.Background(), func(ctx context.Context, work *UnitOfWork) error {
+		return fmt.Errorf("create user: %w", unique)
+	})
+	if !IsUniqueViolation(err) {
+		t.Fatalf("Transaction = %v, want the unique violation", err)
+	}
+
+	if stub.begins != 1 || stub.commits != 0 || stub.rollbacks != 1 {
+		t.Fatalf("begins = %d, commits = %d, rollbacks = %d", stub.begins, stub.commits, stub.rollbacks)
+	}
+}
@@ -14,11 +14,14 @@ type UserModel interface {
 	GetUserList(ctx context.Context, where *Where) (userList []*entities.User, err error)
 	FindUser(ctx context.Context, where *Where) (user *entities.User, err error)
 	UpdateOnlineStatus(ctx context.Context, user *entities.User) (result sql.Result, err error)
+	UpdatePassword(ctx context.Context, user *entities.User) (result sql.Result, err error)
+	PatchUser(ctx context.Context, userId int64, version int64, fields map[string]any, updatedBy int64) (
+		result sql.Result, err error)
 	DeleteUser(ctx context.Context, userId int64) (result sql.Result, err error)
 }
 
 func NewUserModel(db *sql.DB) UserModel {
-	return &PostgresRepository{db}
+	return &PostgresRepository{DB: NewTracedDB(db)}
 }
 
 func (postgres *PostgresRepository) CreateUser(ctx context.Context, user *entities.User) (
@@ -31,7 +34,10 @@ func (postgres *PostgresRepository) CreateUser(ctx context.Context, user *entiti
 			, password
 			, created_by
 			, page
-		) VALUES ($1, $2, $3, $4, $5)
+			
+			, admin
+			, avatar
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
 	return postgres.DB.ExecContext(ctx, queryScript,
@@ -40,6 +46,9 @@ func (postgres *PostgresRepository) CreateUser(ctx context.Context, user *entiti
 		user.Password,
 		user.CreatedBy,
 		user.Page,
+
+		user.Admin,
+		user.Avatar,
 	)
 }
 
@@ -63,6 +72,7 @@ func (postgres *PostgresRepository) GetUserList(ctx context.Context, where *Wher
 		
 				, updated_by
 				, page
+				, admin
 		FROM 	users
 	`
 
@@ -99,6 +109,7 @@ func (postgres *PostgresRepository) GetUserList(ctx context.Context, where *Wher
 
 			&user.UpdatedBy,
 			&user.Page,
+			&user.Admin,
 		)
 
 		if err != nil {
@@ -128,6 +139,8 @@ func (postgres *PostgresRepository) FindUser(ctx context.Context, where *Where)
 		
 				, updated_by
 				, page
+				, admin
+				, version
 		FROM 	users
 	`
 
@@ -150,6 +163,8 @@ func (postgres *PostgresRepository) FindUser(ctx context.Context, where *Where)
 
 		&user.UpdatedBy,
 		&user.Page,
+		&user.Admin,
+		&user.Version,
 	)
 
 	if err != nil {
@@ -162,10 +177,13 @@ func (postgres *PostgresRepository) FindUser(ctx context.Context, where *Where)
 func (postgres *PostgresRepository) UpdateOnlineStatus(ctx context.Context, user *entities.User) (
 	result sql.Result, err error) {
 
+	// the flag is part of the user's representation, so its ETag must change
 	queryScript := `
 		UPDATE 	users SET 
 		        online = $1
-				, updated_by = $2      
+				, updated_by = $2
+				, updated_at = CURRENT_TIMESTAMP
+				, version = version + 1
 		WHERE 	id = $3
 		`
 
@@ -176,6 +194,52 @@ func (postgres *PostgresRepository) UpdateOnlineStatus(ctx context.Context, user
 	)
 }
 
+func (postgres *PostgresRepository) UpdatePassword(ctx context.Context, user *entities.User) (
+	result sql.Result, err error) {
+
+	queryScript := `
+		UPDATE 	users SET 
+		        password = $1
+				, updated_by = $2
+				, updated_at = CURRENT_TIMESTAMP
+				, version = version + 1
+		WHERE 	id = $3
+		`
+
+	return postgres.DB.ExecContext(ctx, queryScript,
+		user.Password,
+		user.UpdatedBy,
+		user.Id,
+	)
+}
+
+var userPatchColumns = map[string]bool{
+	"name":     true,
+	"email":    true,
+	"password": true,
+	"avatar":   true,
+	"page":     true,
+}
+
+// PatchUser updates only the given columns, the name and email are
+// lower-cased like on create. The password must already be hashed.
+func (postgres *PostgresRepository) PatchUser(ctx context.Context, userId int64, version int64,
+	fields map[string]any, updatedBy int64) (result sql.Result, err error) {
+
+	for _, column := range []string{"name", "email"} {
+		if value, ok := fields[column].(string); ok {
+			fields[column] = strings.ToLower(value)
+		}
+	}
+
+	queryScript, values, err := patchQuery("users", userPatchColumns, userId, version, fields, updatedBy)
+	if err != nil {
+		return
+	}
+
+	return postgres.DB.ExecContext(ctx, queryScript, values...)
+}
+
 func (postgres *PostgresRepository) DeleteUser(ctx context.Context, userId int64) (result sql.Result, err error) {
 	queryScript := `DELETE FROM users WHERE id = $1`
 	return postgres.DB.ExecContext(ctx, queryScript, userId)
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/michaelwp/goblog/entities"
+	"github.com/michaelwp/goblog/model"
+	"log"
+)
+
+var SEED_CATEGORIES = []string{"general", "programming", "tutorial"}
+
+const (
+	SEED_ARTICLE_TITLE   = "Welcome to GoBlog"
+	SEED_ARTICLE_CONTENT = "This is a sample article created by goblog seed. Edit or delete it from the admin page."
+	SEED_ARTICLE_TAGS    = "goblog,welcome"
+)
+
+// RunSeed inserts the default categories and a sample article authored by the
+// first administrator. Running it twice does not duplicate anything.
+func RunSeed(args []string) {
+	flagSet := flag.NewFlagSet("seed", flag.ExitOnError)
+	appConfig := LoadConfig(flagSet, args)
+
+	postgres, err := SetupPostgres(appConfig)
+	if err != nil {
+		log.Fatal("error connect to postgres", err)
+	}
+
+	defer func() {
+		_ = postgres.Close()
+	}()
+
+	ctx := context.Background()
+
+	admin, err := model.NewUserModel(postgres).FindUser(ctx, &model.Where{
+		Parameter: "WHERE admin=$1 ORDER BY id LIMIT 1",
+		Values:    []any{true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Fatal("seed: no administrator found, run `goblog user create -admin` first")
+	}
+
+	if err != nil {
+		log.Fatal("error find administrator: ", err)
+	}
+
+	categoryModel := model.NewCategoryModel(postgres, nil)
+
+	var firstCategory *entities.Category
+	for _, name := range SEED_CATEGORIES {
+		category, err := SeedCategory(ctx, categoryModel, name, admin.Id)
+		if err != nil {
+			log.Fatal("error seed category: ", err)
+		}
+
+		if firstCategory == nil {
+			firstCategory = category
+		}
+	}
+
+	articleModel := model.NewArticleModel(postgres, nil)
+
+	articleList, err := articleModel.GetArticleList(ctx, &model.Where{
+		Parameter: "WHERE a.title=$1",
+		Values:    []any{SEED_ARTICLE_TITLE},
+	})
+	if err != nil {
+		log.Fatal("error find sample article: ", err)
+	}
+
+	if len(articleList) == 0 {
+		tags := SEED_ARTICLE_TAGS
+
+		_, err = articleModel.CreateArticle(ctx, &entities.Article{
+			UserId:     admin.Id,
+			CategoryId: firstCategory.Id,
+			Title:      SEED_ARTICLE_TITLE,
+			Content:    SEED_ARTICLE_CONTENT,
+			Tags:       &tags,
+			CreatedBy:  admin.Id,
+		})
+		if err != nil {
+			log.Fatal("error seed article: ", err)
+		}
+
+		fmt.Println("article created:", SEED_ARTICLE_TITLE)
+	}
+
+	fmt.Println("seed completed")
+}
+
+func SeedCategory(ctx context.Context, categoryModel model.CategoryModel, name string, createdBy int64) (
+	category *entities.Category, err error) {
+
+	where := &model.Where{
+		Parameter: "WHERE name=$1",
+		Values:    []any{name},
+	}
+
+	category, err = categoryModel.FindCategory(ctx, where)
+	if err == nil {
+		return
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	_, err = categoryModel.CreateCategory(ctx, &entities.Category{Name: name, CreatedBy: createdBy})
+	if err != nil {
+		return
+	}
+
+	fmt.Println("category created:", name)
+	return categoryModel.FindCategory(ctx, where)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/gin-gonic/gin"
+	"github.com/michaelwp/goblog/api"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func RunServe(args []string) {
+	flagSet := flag.NewFlagSet("serve", flag.ExitOnError)
+	appConfig := LoadConfig(flagSet, args)
+
+	log.Println("configuration loaded:\n" + appConfig.String())
+
+	gin.SetMode(appConfig.GinMode)
+
+	shutdownTracing, err := SetupTracing(appConfig)
+	if err != nil {
+		log.Fatal("error setup tracing", err)
+	}
+
+	defer func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			log.Println("error shutdown tracing:", err)
+		}
+	}()
+
+	r := gin.Default()
+
+	err = r.SetTrustedProxies(appConfig.Server.TrustedProxies)
+	if err != nil {
+		log.Fatal("error set trusted proxies", err)
+	}
+
+	controllerConfig := SetupConfig(appConfig)
+	api.NewRouter(r, controllerConfig)
+
+	server := appConfig.Server.NewServer(r)
+
+	go func() {
+		log.Println("server started on port " + appConfig.Server.Addr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	<-ctx.Done()
+	stop()
+
+	log.Println("shutting down server")
+	controllerConfig.SetShuttingDown()
+	time.Sleep(appConfig.Server.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), appConfig.Server.GetShutdownTimeout())
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Println("error shutdown server:", err)
+	}
+
+	err = controllerConfig.Close()
+	if err != nil {
+		log.Println("error close connections:", err)
+	}
+
+	log.Println("server stopped")
+}
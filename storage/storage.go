@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned by Open for a key that was never stored or was deleted.
+var ErrNotFound = errors.New("object not found")
+
+// Storage keeps uploaded files under slash separated keys.
+type Storage interface {
+	// Put stores size bytes of body under key, replacing an existing object.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes key, a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// CheckKey rejects keys that are empty, absolute or step out of the storage
+// root, so they are safe as file paths and object names.
+func CheckKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package tool
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to document. Members set to
+// null in patch are removed, objects are merged recursively and any other
+// value replaces the original one.
+func MergePatch(document, patch []byte) ([]byte, error) {
+	var patchValue any
+	err := json.Unmarshal(patch, &patchValue)
+	if err != nil {
+		return nil, err
+	}
+
+	var documentValue any
+	if len(document) > 0 {
+		err = json.Unmarshal(document, &documentValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(mergeValue(documentValue, patchValue))
+}
+
+// ParseMergePatch decodes a merge patch that must be a JSON object, which is
+// the only form able to update individual members of a resource.
+func ParseMergePatch(patch []byte) (map[string]json.RawMessage, error) {
+	var members map[string]json.RawMessage
+	err := json.Unmarshal(patch, &members)
+	if err != nil {
+		return nil, err
+	}
+
+	if members == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	return members, nil
+}
+
+func mergeValue(document, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	documentObject, ok := document.(map[string]any)
+	if !ok {
+		documentObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(documentObject, key)
+			continue
+		}
+
+		documentObject[key] = mergeValue(documentObject[key], value)
+	}
+
+	return documentObject
+}
@@ -0,0 +1,69 @@
+package tool
+
+import (
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// the examples of RFC 7396 appendix A, json.Marshal sorts object keys
+	for name, test := range map[string]struct {
+		document string
+		patch    string
+		want     string
+	}{
+		"replace member":        {`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		"add member":            {`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		"remove member":         {`{"a":"b"}`, `{"a":null}`, `{}`},
+		"remove one of two":     {`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		"replace array":         {`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		"array replaces value":  {`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		"nested object":         {`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		"arrays are not merged": {`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		"non-object document":   {`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		"array replaces object": {`{"a":"b"}`, `["c"]`, `["c"]`},
+		"null patch":            {`{"a":"foo"}`, `null`, `null`},
+		"string patch":          {`{"a":"foo"}`, `"bar"`, `"bar"`},
+		"document null kept":    {`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		"array to object":       {`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		"new nested object":     {`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		"empty document":        {``, `{"a":1}`, `{"a":1}`},
+	} {
+		got, err := MergePatch([]byte(test.document), []byte(test.patch))
+		if err != nil {
+			t.Errorf("%s: MergePatch = %v", name, err)
+			continue
+		}
+
+		if string(got) != test.want {
+			t.Errorf("%s: MergePatch(%s, %s) = %s, want %s", name, test.document, test.patch, got, test.want)
+		}
+	}
+}
+
+func TestMergePatchInvalidJSON(t *testing.T) {
+	if _, err := MergePatch([]byte(`{"a":1}`), []byte(`{"a":`)); err == nil {
+		t.Fatal("MergePatch of an invalid patch succeeded")
+	}
+
+	if _, err := MergePatch([]byte(`{"a":`), []byte(`{"a":1}`)); err == nil {
+		t.Fatal("MergePatch of an invalid document succeeded")
+	}
+}
+
+func TestParseMergePatch(t *testing.T) {
+	members, err := ParseMergePatch([]byte(`{"name":"go","page":null}`))
+	if err != nil {
+		t.Fatalf("ParseMergePatch = %v", err)
+	}
+
+	if len(members) != 2 || string(members["name"]) != `"go"` || string(members["page"]) != "null" {
+		t.Fatalf("members = %v", members)
+	}
+
+	// only objects can update individual members
+	for _, patch := range []string{`null`, `[]`, `"name"`, `1`, `true`, `{`} {
+		if _, err := ParseMergePatch([]byte(patch)); err == nil {
+			t.Errorf("ParseMergePatch(%s) succeeded", patch)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
-	"os"
 	"time"
 )
 
@@ -33,7 +32,7 @@ func CompareHashAndPassword(hash []byte, password []byte) (err error) {
 	return
 }
 
-func GenerateJWT(id int64) (signed string, err error) {
+func GenerateJWT(id int64, signingKey []byte) (signed string, err error) {
 	claims := JwtCustomClaim{
 		id,
 		jwt.RegisteredClaims{
@@ -43,12 +42,10 @@ func GenerateJWT(id int64) (signed string, err error) {
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SIGNING_KEY")))
+	return token.SignedString(signingKey)
 }
 
-func VerifyJWT(tokenString string) (claims jwt.MapClaims, err error) {
-	signingKey := []byte(os.Getenv("JWT_SIGNING_KEY"))
-
+func VerifyJWT(tokenString string, signingKey []byte) (claims jwt.MapClaims, err error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		return signingKey, nil
 	})
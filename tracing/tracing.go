@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const SERVICE_NAME = "goblog"
+
+const (
+	EXPORTER_NONE = "none"
+	EXPORTER_OTLP = "otlp"
+)
+
+// Config selects the span exporter. The OTLP/HTTP exporter reads its endpoint,
+// headers and TLS settings from the standard OTEL_EXPORTER_OTLP_* variables.
+type Config struct {
+	// Exporter is either "none" (default) or "otlp".
+	Exporter string
+}
+
+// Setup installs the global tracer provider and the W3C trace-context propagator.
+// With the "none" exporter a no-op provider is used, so the application runs
+// without a collector. The returned function flushes and stops the provider.
+func Setup(ctx context.Context, config *Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if config.Exporter == "" || config.Exporter == EXPORTER_NONE {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if config.Exporter != EXPORTER_OTLP {
+		return nil, fmt.Errorf("unknown traces exporter %q", config.Exporter)
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(SERVICE_NAME),
+	))
+	if err != nil {
+		return
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
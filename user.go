@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/michaelwp/goblog/entities"
+	"github.com/michaelwp/goblog/model"
+	"github.com/michaelwp/goblog/tool"
+	"golang.org/x/term"
+	"log"
+	"os"
+	"strings"
+)
+
+const USER_USAGE = "usage: goblog user create|reset-password [flags]"
+
+func RunUser(args []string) {
+	if len(args) < 1 {
+		log.Fatal(USER_USAGE)
+	}
+
+	switch args[0] {
+	case "create":
+		RunUserCreate(args[1:])
+	case "reset-password":
+		RunUserResetPassword(args[1:])
+	default:
+		log.Fatal(USER_USAGE)
+	}
+}
+
+func RunUserCreate(args []string) {
+	flagSet := flag.NewFlagSet("user create", flag.ExitOnError)
+	name := flagSet.String("name", "", "user name (required)")
+	email := flagSet.String("email", "", "user email (required)")
+	password := flagSet.String("password", "", "user password, prompted when empty")
+	admin := flagSet.Bool("admin", false, "grant administrator rights")
+	appConfig := LoadConfig(flagSet, args)
+
+	if *name == "" || *email == "" {
+		log.Fatal("user create: -name and -email are required")
+	}
+
+	postgres, err := SetupPostgres(appConfig)
+	if err != nil {
+		log.Fatal("error connect to postgres", err)
+	}
+
+	defer func() {
+		_ = postgres.Close()
+	}()
+
+	ctx := context.Background()
+	userModel := model.NewUserModel(postgres)
+
+	_, err = FindUserByEmail(ctx, userModel, *email)
+	if err == nil {
+		log.Fatal("user create: email already registered")
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Fatal("error find user: ", err)
+	}
+
+	hash, err := tool.GenerateHash(ReadPassword(*password))
+	if err != nil {
+		log.Fatal("error hash password: ", err)
+	}
+
+	user := &entities.User{
+		Name:     *name,
+		Email:    *email,
+		Password: string(hash),
+		Admin:    entities.Status(*admin),
+	}
+
+	_, err = userModel.CreateUser(ctx, user)
+	if err != nil {
+		log.Fatal("error create user: ", err)
+	}
+
+	fmt.Println("user created:", strings.ToLower(*email))
+}
+
+func RunUserResetPassword(args []string) {
+	flagSet := flag.NewFlagSet("user reset-password", flag.ExitOnError)
+	email := flagSet.String("email", "", "user email (required)")
+	password := flagSet.String("password", "", "new password, prompted when empty")
+	appConfig := LoadConfig(flagSet, args)
+
+	if *email == "" {
+		log.Fatal("user reset-password: -email is required")
+	}
+
+	postgres, err := SetupPostgres(appConfig)
+	if err != nil {
+		log.Fatal("error connect to postgres", err)
+	}
+
+	defer func() {
+		_ = postgres.Close()
+	}()
+
+	ctx := context.Background()
+	userModel := model.NewUserModel(postgres)
+
+	user, err := FindUserByEmail(ctx, userModel, *email)
+	if err != nil {
+		log.Fatal("error find user: ", err)
+	}
+
+	hash, err := tool.GenerateHash(ReadPassword(*password))
+	if err != nil {
+		log.Fatal("error hash password: ", err)
+	}
+
+	user.Password = string(hash)
+	user.UpdatedBy = &user.Id
+
+	_, err = userModel.UpdatePassword(ctx, user)
+	if err != nil {
+		log.Fatal("error update password: ", err)
+	}
+
+	fmt.Println("password reset:", user.Email)
+}
+
+func FindUserByEmail(ctx context.Context, userModel model.UserModel, email string) (*entities.User, error) {
+	where := &model.Where{
+		Parameter: "WHERE email=$1",
+		Values:    []any{strings.ToLower(email)},
+	}
+
+	return userModel.FindUser(ctx, where)
+}
+
+// ReadPassword returns password, or prompts for it on the terminal
+// (or reads one line from stdin) when it is empty.
+func ReadPassword(password string) []byte {
+	if password != "" {
+		return []byte(password)
+	}
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "password: ")
+		input, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			log.Fatal("error read password: ", err)
+		}
+
+		password = string(input)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatal("error read password: ", err)
+		}
+
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if password == "" {
+		log.Fatal("password required")
+	}
+
+	return []byte(password)
+}